@@ -3,46 +3,129 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/ponyo877/flappy-ranking/common"
 )
 
-func (g *Game) fetchToken() {
-	resp, err := http.Post(endpoint.JoinPath("api", "tokens").String(), "application/json", nil)
+// errChallengeUnavailable is returned by fetchToken when the server has the daily challenge disabled.
+var errChallengeUnavailable = errors.New("daily challenge unavailable")
+
+// fetchToken starts a session on the course at the selected difficulty. If ghostID is not 0,
+// the session flies the course and difficulty of that replay and the replay is set up as the ghost to race against.
+// The session of the previous game is cleared first, so that a failed request leaves none.
+func (g *Game) fetchToken(course common.Course, ghostID int) error {
+	g.token, g.pipes = "", nil
+	g.ghost, g.ghostScore = nil, nil
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(struct {
+		Course       common.Course       `json:"course"`
+		Difficulty   common.Difficulty   `json:"difficulty"`
+		RulesVersion common.RulesVersion `json:"rulesVersion"`
+		GhostID      int                 `json:"ghostId,omitempty"`
+	}{course, g.difficulty, common.RulesVersionCurrent, ghostID}); err != nil {
+		return fmt.Errorf("marshal token request: %w", err)
+	}
+	resp, err := http.Post(endpoint.JoinPath("api", "tokens").String(), "application/json", &body)
 	if err != nil {
-		log.Printf("Failed to get token: %v", err)
-		return
+		return fmt.Errorf("get token: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusServiceUnavailable && course == common.CourseChallenge {
+		return errChallengeUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch token: %s", resp.Status)
+	}
+
 	var result struct {
-		Token   string `json:"token"`
-		PipeKey string `json:"pipeKey"`
+		Token      string            `json:"token"`
+		Pipes      *pipesJSON        `json:"pipes"`
+		Difficulty common.Difficulty `json:"difficulty"`
+		Ghost      *struct {
+			DisplayName  string              `json:"displayName"`
+			Score        int                 `json:"score"`
+			Difficulty   common.Difficulty   `json:"difficulty"`
+			RulesVersion common.RulesVersion `json:"rulesVersion"`
+			JumpHistory  []int               `json:"jumpHistory"`
+			Pipes        *pipesJSON          `json:"pipes"`
+		} `json:"ghost"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("Failed to decode token response: %v", err)
-		return
+		return fmt.Errorf("decode token response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to fetch token: %s", resp.Status)
-		return
+	g.token = result.Token
+	g.pipes = result.Pipes.segment()
+	if result.Difficulty.IsValid() {
+		g.rules = result.Difficulty.Rules()
+	}
+	if result.Ghost == nil {
+		return nil
 	}
+	// The ghost flies with the rules it was recorded with, which may be older than ours.
+	ghostRules, ok := common.LookupRules(result.Ghost.RulesVersion, result.Ghost.Difficulty)
+	if !ok {
+		// The game can still be played without the ghost.
+		log.Printf("Unsupported rules of ghost: %d %s", result.Ghost.RulesVersion, result.Ghost.Difficulty)
+		return nil
+	}
+	// The pipes of the ghost are those of its replay, which may reach further than the ones revealed to us.
+	g.ghost = common.NewReplayer(newCourseObject("", result.Ghost.Pipes.segment(), ghostRules), result.Ghost.JumpHistory)
+	g.ghostScore = common.NewScore(ghostID, 0, result.Ghost.DisplayName, result.Ghost.Score, time.Time{})
+	return nil
+}
 
-	g.token = result.Token
-	g.pipeKey = result.PipeKey
-	// log.Printf("Got token: %s, pipeKey: %s", g.token, g.pipeKey)
+// fetchTopScoreID returns the ID of today's best score at the selected difficulty, or 0 if there is none.
+func (g *Game) fetchTopScoreID() int {
+	endpoint := endpoint.JoinPath("api", "scores")
+	q := endpoint.Query()
+	q.Set("period", "DAILY")
+	q.Set("difficulty", string(g.difficulty))
+	endpoint.RawQuery = q.Encode()
+
+	resp, err := http.Get(endpoint.String())
+	if err != nil {
+		log.Printf("Failed to fetch ranking: %v", err)
+		return 0
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Scores []struct {
+			ID int `json:"id"`
+		} `json:"scores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode ranking response: %v", err)
+		return 0
+	}
+	if len(result.Scores) == 0 {
+		return 0
+	}
+	return result.Scores[0].ID
 }
 
-func (g *Game) fetchRanking() {
+// fetchRanking fetches the page of the selected board that starts at cursor.
+// The last row of the page is selected if selectLast is true.
+func (g *Game) fetchRanking(cursor string, selectLast bool) {
 	g.fetchingRanking = true
 	endpoint := endpoint.JoinPath("api", "scores")
 	q := endpoint.Query()
 	q.Set("period", g.rankingPeriod)
+	q.Set("course", string(g.rankingCourse))
+	q.Set("difficulty", string(g.difficulty))
+	q.Set("limit", strconv.Itoa(rankingPageSize))
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
 	endpoint.RawQuery = q.Encode()
 
 	resp, err := http.Get(endpoint.String())
@@ -55,11 +138,13 @@ func (g *Game) fetchRanking() {
 
 	var result struct {
 		Scores []struct {
+			ID          int       `json:"id"`
 			Rank        int       `json:"rank"`
 			DisplayName string    `json:"display_name"`
 			Score       int       `json:"score"`
 			CreatedAt   time.Time `json:"created_at"`
 		} `json:"scores"`
+		NextCursor string `json:"nextCursor"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -71,17 +156,202 @@ func (g *Game) fetchRanking() {
 	g.rankings = nil
 	for _, s := range result.Scores {
 		g.rankings = append(g.rankings, common.NewScore(
-			s.Rank, s.DisplayName, s.Score, s.CreatedAt))
+			s.ID, s.Rank, s.DisplayName, s.Score, s.CreatedAt))
+	}
+	g.rankingNext = result.NextCursor
+	g.rankingCursor = 0
+	if selectLast && len(g.rankings) > 0 {
+		g.rankingCursor = len(g.rankings) - 1
 	}
 	g.fetchingRanking = false
 }
 
+// fetchProfile fetches the profile of the player playerID of this device on the selected difficulty.
+func (g *Game) fetchProfile(playerID int) {
+	defer func() { g.fetchingProfile = false }()
+	g.profile = nil
+	if playerID == 0 {
+		// The player is registered on the first run and may not be yet.
+		player, ok := g.signIn()
+		if !ok {
+			return
+		}
+		playerID = player.id
+	}
+	endpoint := endpoint.JoinPath("api", "players", strconv.Itoa(playerID))
+	q := endpoint.Query()
+	q.Set("course", string(common.CourseRandom))
+	q.Set("difficulty", string(g.difficulty))
+	endpoint.RawQuery = q.Encode()
+
+	resp, err := http.Get(endpoint.String())
+	if err != nil {
+		log.Printf("Failed to fetch profile: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch profile: %s", resp.Status)
+		return
+	}
+
+	type run struct {
+		ID        int       `json:"id"`
+		Score     int       `json:"score"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+	var result struct {
+		DisplayName  string          `json:"displayName"`
+		Plays        int             `json:"plays"`
+		AverageScore float64         `json:"averageScore"`
+		Bests        map[string]*run `json:"bests"`
+		RecentRuns   []run           `json:"recentRuns"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode profile: %v", err)
+		return
+	}
+
+	p := &playerProfile{
+		DisplayName:  result.DisplayName,
+		Plays:        result.Plays,
+		AverageScore: result.AverageScore,
+		Bests:        make(map[string]*common.Score),
+	}
+	for period, r := range result.Bests {
+		if r != nil {
+			p.Bests[period] = common.NewScore(r.ID, 0, result.DisplayName, r.Score, r.CreatedAt)
+		}
+	}
+	for _, r := range result.RecentRuns {
+		p.RecentRuns = append(p.RecentRuns, common.NewScore(r.ID, 0, result.DisplayName, r.Score, r.CreatedAt))
+	}
+	g.profile = p
+}
+
+func (g *Game) fetchReplay(id int) {
+	g.fetchingReplay = true
+	resp, err := http.Get(endpoint.JoinPath("api", "replays", strconv.Itoa(id)).String())
+	if err != nil {
+		log.Printf("Failed to fetch replay: %v", err)
+		g.fetchingReplay = false
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch replay: %s", resp.Status)
+		g.fetchingReplay = false
+		return
+	}
+
+	var result struct {
+		PipeKey      string              `json:"pipeKey"`
+		Pipes        *pipesJSON          `json:"pipes"`
+		Difficulty   common.Difficulty   `json:"difficulty"`
+		RulesVersion common.RulesVersion `json:"rulesVersion"`
+		JumpHistory  []int               `json:"jumpHistory"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode replay response: %v", err)
+		g.fetchingReplay = false
+		return
+	}
+	rules, ok := common.LookupRules(result.RulesVersion, result.Difficulty)
+	if !ok {
+		log.Printf("Unsupported rules of replay: %d %s", result.RulesVersion, result.Difficulty)
+		g.fetchingReplay = false
+		return
+	}
+
+	g.obj = newCourseObject(result.PipeKey, result.Pipes.segment(), rules)
+	g.replayer = common.NewReplayer(g.obj, result.JumpHistory)
+	g.fetchingReplay = false
+}
+
+// registeredPlayer is the player of this device as registered by the server.
+type registeredPlayer struct {
+	id          int
+	displayName string
+}
+
+// signIn registers the player of this device in the background, and passes it to Update as well.
+func (g *Game) signIn() (registeredPlayer, bool) {
+	player, ok := g.registerPlayer("")
+	if !ok {
+		return registeredPlayer{}, false
+	}
+	select {
+	case g.registeredPlayers <- player:
+	default:
+		log.Printf("Dropped the registered player %d", player.id)
+	}
+	return player, true
+}
+
+// setPlayer signs in as the registered player. The name of the player fills in an empty name field.
+// It must be called on the game loop, which reads what it sets.
+func (g *Game) setPlayer(player registeredPlayer) {
+	g.playerID = player.id
+	if g.nameInput.Text() == "" {
+		g.nameInput.SetText(player.displayName)
+	}
+}
+
+// registerPlayer registers the player of this device, or signs in as it after the first run.
+// A non-empty displayName renames the player. It only talks to the server, so it can be called in the background.
+func (g *Game) registerPlayer(displayName string) (registeredPlayer, bool) {
+	if g.playerKey == "" {
+		return registeredPlayer{}, false
+	}
+	jsonData, err := json.Marshal(struct {
+		Key         string `json:"key"`
+		DisplayName string `json:"displayName"`
+	}{g.playerKey, displayName})
+	if err != nil {
+		log.Printf("Failed to marshal player data: %v", err)
+		return registeredPlayer{}, false
+	}
+	resp, err := http.Post(endpoint.JoinPath("api", "players").String(), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Printf("Failed to register player: %v", err)
+		return registeredPlayer{}, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to register player: %s", resp.Status)
+		return registeredPlayer{}, false
+	}
+	var result struct {
+		ID          int    `json:"id"`
+		DisplayName string `json:"displayName"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode player: %v", err)
+		return registeredPlayer{}, false
+	}
+	return registeredPlayer{result.ID, result.DisplayName}, true
+}
+
 func (g *Game) submitScore(playerName string) {
+	// Named scores are tied to the player of this device, scores with no name are not.
+	var playerKey string
+	if name := g.nameInput.Text(); name != "" {
+		if player, ok := g.registerPlayer(name); ok {
+			g.setPlayer(player)
+			playerKey = g.playerKey
+		}
+	}
 	data := struct {
 		DisplayName string `json:"displayName"`
+		PlayerKey   string `json:"playerKey,omitempty"`
 		JumpHistory []int  `json:"jumpHistory"`
 	}{
 		DisplayName: playerName,
+		PlayerKey:   playerKey,
 		JumpHistory: g.jumpHistory,
 	}
 
@@ -99,6 +369,40 @@ func (g *Game) submitScore(playerName string) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		// Conflicts of the session have a plain text body, copied replays a JSON one.
+		var rejection struct {
+			Error string `json:"error"`
+		}
+		g.errorMessage = "Score already submitted"
+		if err := json.NewDecoder(resp.Body).Decode(&rejection); err == nil && rejection.Error == "COPIED_REPLAY" {
+			// Submitting the same replay again would be rejected again.
+			g.errorMessage = "Replay copies another score"
+		}
+		g.scoreSubmitted = true
+		log.Printf("Failed to submit score: %s %s", resp.Status, rejection.Error)
+		return
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		var rejection struct {
+			Error  string `json:"error"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&rejection); err == nil {
+			switch rejection.Error {
+			case "INVALID_DISPLAY_NAME":
+				g.errorMessage = displayNameMessage(rejection.Reason)
+				log.Printf("Failed to submit score: invalid display name: %s", rejection.Reason)
+				return
+			case "INVALID_JUMP_HISTORY":
+				// Submitting the same replay again would be rejected again.
+				g.errorMessage = "Replay was rejected"
+				g.scoreSubmitted = true
+				log.Printf("Failed to submit score: invalid jump history: %s", rejection.Reason)
+				return
+			}
+		}
+	}
 	if resp.StatusCode != http.StatusOK {
 		g.errorMessage = "Server error: " + resp.Status
 		log.Printf("Failed to submit score: %s", resp.Status)
@@ -106,6 +410,157 @@ func (g *Game) submitScore(playerName string) {
 	}
 	g.scoreSubmitted = true
 	// log.Printf("Score submitted successfully")
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode score response: %v", err)
+		return
+	}
+	g.scoreID = result.ID
+	go g.fetchAround(result.ID)
+}
+
+// displayNameMessage explains why the server rejected the display name.
+func displayNameMessage(reason string) string {
+	switch reason {
+	case "TOO_LONG":
+		return fmt.Sprintf("NAME IS LONGER THAN %d LETTERS", common.MaxDisplayNameLength)
+	case "BLOCKED":
+		return "NAME IS NOT ALLOWED"
+	default:
+		return "INVALID NAME"
+	}
+}
+
+// fetchAround fetches the rank of the score id and its neighbours on today's board.
+func (g *Game) fetchAround(id int) {
+	endpoint := endpoint.JoinPath("api", "scores", strconv.Itoa(id), "around")
+	q := endpoint.Query()
+	q.Set("period", "DAILY")
+	endpoint.RawQuery = q.Encode()
+
+	resp, err := http.Get(endpoint.String())
+	if err != nil {
+		log.Printf("Failed to fetch scores around: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch scores around: %s", resp.Status)
+		return
+	}
+
+	var result struct {
+		Rank   int `json:"rank"`
+		ID     int `json:"id"`
+		Scores []struct {
+			ID          int       `json:"id"`
+			Rank        int       `json:"rank"`
+			DisplayName string    `json:"display_name"`
+			Score       int       `json:"score"`
+			CreatedAt   time.Time `json:"created_at"`
+		} `json:"scores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode scores around: %v", err)
+		return
+	}
+
+	var scores []*common.Score
+	for _, s := range result.Scores {
+		scores = append(scores, common.NewScore(s.ID, s.Rank, s.DisplayName, s.Score, s.CreatedAt))
+	}
+	if g.scoreID != id {
+		// The player has already moved on to another game.
+		return
+	}
+	g.aroundID = result.ID
+	g.aroundRank = result.Rank
+	g.aroundScores = scores
+}
+
+// pipesJSON is a segment of the course revealed by the server.
+type pipesJSON struct {
+	From   int   `json:"from"`
+	TileYs []int `json:"tileYs"`
+}
+
+func (p *pipesJSON) segment() *common.PipeSegment {
+	if p == nil {
+		return nil
+	}
+	return &common.PipeSegment{From: p.From, TileYs: p.TileYs}
+}
+
+// revealedPipes are the pipes revealed by a checkpoint of the session of token.
+type revealedPipes struct {
+	token string
+	pipes *common.PipeSegment
+}
+
+const (
+	// checkpointAttempts is how many times a checkpoint is reported before its pipes are given up,
+	// waiting checkpointRetryDelay in between. The pipes of the next checkpoint still come in time.
+	checkpointAttempts   = 3
+	checkpointRetryDelay = 500 * time.Millisecond
+)
+
+// reportCheckpoint tells the server that the session of token has passed score pipes,
+// and passes the pipes it reveals to Update.
+// It is called in the background. A report that fails on the way is retried, since the gopher
+// crashes into the pipes that are not revealed, but one the server rejects is not.
+func (g *Game) reportCheckpoint(token string, score int) {
+	body, err := json.Marshal(struct {
+		Score int `json:"score"`
+	}{score})
+	if err != nil {
+		log.Printf("Failed to marshal checkpoint: %v", err)
+		return
+	}
+	var pipes *pipesJSON
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		pipes, retry, err = postCheckpoint(token, body)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to report checkpoint %d (attempt %d): %v", score, attempt, err)
+		if !retry || attempt == checkpointAttempts {
+			return
+		}
+		time.Sleep(checkpointRetryDelay)
+	}
+	if pipes == nil {
+		return
+	}
+	select {
+	case g.revealedPipes <- revealedPipes{token, pipes.segment()}:
+	default:
+		log.Printf("Dropped the pipes revealed at checkpoint %d", score)
+	}
+}
+
+// postCheckpoint posts the checkpoint body of the session of token and returns the pipes it reveals.
+// It reports whether a failure may succeed on retry, which those of the network and the server may.
+func postCheckpoint(token string, body []byte) (*pipesJSON, bool, error) {
+	resp, err := http.Post(endpoint.JoinPath("api", "sessions", token, "checkpoints").String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode >= http.StatusInternalServerError, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var result struct {
+		Pipes *pipesJSON `json:"pipes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, fmt.Errorf("decode checkpoint response: %w", err)
+	}
+	return result.Pipes, false, nil
 }
 
 func (g *Game) finishSession() {
@@ -120,5 +575,16 @@ func (g *Game) finishSession() {
 		log.Printf("Failed to finish session: %s", resp.Status)
 		return
 	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode finished session: %v", err)
+		return
+	}
+	// Signed sessions are submitted with a new token that holds the finish time.
+	if result.Token != "" {
+		g.token = result.Token
+	}
 	// log.Printf("Session finished successfully")
 }
@@ -17,6 +17,7 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
@@ -43,10 +44,13 @@ var (
 	gopherImage      *ebiten.Image
 	tilesImage       *ebiten.Image
 	arcadeFaceSource *text.GoTextFaceSource
-	buttonColor1     = color.RGBA{0x60, 0x80, 0xa0, 0xff}
-	buttonColor2     = color.RGBA{0x60, 0x60, 0x80, 0xff}
-	endpoint         *url.URL
-	serverURL        string
+	// mplusFaceSource draws the glyphs missing from the arcade font, such as those of Japanese names.
+	mplusFaceSource *text.GoTextFaceSource
+	nameFaces       = map[float64]text.Face{}
+	buttonColor1    = color.RGBA{0x60, 0x80, 0xa0, 0xff}
+	buttonColor2    = color.RGBA{0x60, 0x60, 0x80, 0xff}
+	endpoint        *url.URL
+	serverURL       string
 )
 
 func init() {
@@ -66,6 +70,11 @@ func init() {
 		log.Fatal(err)
 	}
 	arcadeFaceSource = s
+	s, err = text.NewGoTextFaceSource(bytes.NewReader(fonts.MPlus1pRegular_ttf))
+	if err != nil {
+		log.Fatal(err)
+	}
+	mplusFaceSource = s
 	endpoint, err = url.Parse(serverURL)
 	if err != nil {
 		log.Fatal(err)
@@ -79,6 +88,8 @@ const (
 	ModeGame
 	ModeGameOver
 	ModeRanking
+	ModeReplay
+	ModeProfile
 )
 
 type Game struct {
@@ -99,29 +110,109 @@ type Game struct {
 	hitPlayer    *audio.Player
 
 	jumpHistory []int
+	// checkpointScore is the score of the last checkpoint reported to the server.
+	checkpointScore int
+
+	// difficulty is selected on the title screen and the ranking screen.
+	// rules are the rules of the current session, which may follow the difficulty of a ghost.
+	difficulty common.Difficulty
+	rules      common.Rules
 
 	token        string
-	pipeKey      string
-	playerName   string
+	nameInput    *TextInput
 	errorMessage string
 
+	// The first pipes of the session, whose course is revealed progressively since it has no pipe key
+	pipes *common.PipeSegment
+	// The pipes revealed by checkpoints in the background
+	revealedPipes chan revealedPipes
+	// The revealed pipes that don't follow the known ones yet, since an earlier segment is still to come
+	pendingPipes []*common.PipeSegment
+	// courseLost is set when the gopher reaches pipes that were never revealed, which the server won't score.
+	courseLost bool
+
+	// The anonymous player of this device
+	playerKey string
+	playerID  int
+	// The player registered in the background
+	registeredPlayers chan registeredPlayer
+
 	scoreSubmitted bool
 
+	// The submitted score and its neighbours on today's board,
+	// where the entry of the player is aroundID, their best score of the day.
+	scoreID      int
+	aroundID     int
+	aroundRank   int
+	aroundScores []*common.Score
+
 	rankings        []*common.Score
 	rankingPeriod   string // "DAILY", "WEEKLY", "MONTHLY"
+	rankingCourse   common.Course
+	rankingCursor   int
+	rankingPage     string   // cursor of the current page, "" on the first page
+	rankingPrevs    []string // cursors of the pages before the current one
+	rankingNext     string   // cursor of the next page, "" on the last page
 	fetchingRanking bool
 
-	rankingButton     Button
-	dailyButton       Button
-	weeklyButton      Button
-	monthlyButton     Button
-	backButton        Button
-	submitScoreButton Button
+	replayer       *common.Replayer
+	replayScore    *common.Score
+	fetchingReplay bool
+	// replayReturnMode is the screen a replay returns to.
+	replayReturnMode Mode
+
+	// Profile of the player of this device on the selected difficulty
+	profile         *playerProfile
+	profileCursor   int
+	fetchingProfile bool
+
+	// Ghost racing against a leaderboard replay
+	ghost      *common.Replayer
+	ghostScore *common.Score
+
+	rankingButton          Button
+	ghostButton            Button
+	challengeButton        Button
+	difficultyButton       Button
+	profileButton          Button
+	dailyButton            Button
+	weeklyButton           Button
+	monthlyButton          Button
+	challengeRankingButton Button
+	backButton             Button
+	prevPageButton         Button
+	nextPageButton         Button
+	submitScoreButton      Button
+}
+
+// nameFace returns the arcade face of the size that falls back to M+ for the characters it lacks.
+// Text that may contain player names is drawn with it.
+func nameFace(size float64) text.Face {
+	if f, ok := nameFaces[size]; ok {
+		return f
+	}
+	f, err := text.NewMultiFace(
+		&text.GoTextFace{Source: arcadeFaceSource, Size: size},
+		&text.GoTextFace{Source: mplusFaceSource, Size: size},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nameFaces[size] = f
+	return f
 }
 
 func NewGame() ebiten.Game {
-	g := &Game{}
+	g := &Game{
+		difficulty: common.DifficultyNormal,
+		playerKey:  loadPlayerKey(),
+		// Space continues to the title screen.
+		nameInput: newTextInput(common.MaxDisplayNameLength, func(r rune) bool { return r != ' ' }),
+		// Registered at start and by the profile screen, which fetches one profile at a time.
+		registeredPlayers: make(chan registeredPlayer, 2),
+	}
 	g.init()
+	go g.signIn()
 	return g
 }
 
@@ -151,25 +242,83 @@ func (g *Game) init() {
 		log.Fatal(err)
 	}
 	g.jumpHistory = []int{}
+	g.errorMessage = ""
 	g.scoreSubmitted = false
+	g.scoreID = 0
+	g.aroundID = 0
+	g.checkpointScore = 0
+	g.pendingPipes = nil
+	g.courseLost = false
+	if g.revealedPipes == nil {
+		g.revealedPipes = make(chan revealedPipes, 8)
+	}
+	g.aroundRank = 0
+	g.aroundScores = nil
+	g.ghost = nil
+	g.ghostScore = nil
+
+	titleButtonWidth := 180
+	titleButtonSpacing := 10
+	titleButtonX := (common.ScreenWidth - 3*titleButtonWidth - 2*titleButtonSpacing) / 2
 
 	g.rankingButton = newButton(
-		common.ScreenWidth/2-160,
+		titleButtonX,
 		common.ScreenHeight-100,
-		320,
+		titleButtonWidth,
 		60,
 		"RANKING",
-		common.FontSize,
+		common.MiddleFontSize,
 		buttonColor1,
 	)
 
-	buttonWidth := 100
+	g.ghostButton = newButton(
+		titleButtonX+titleButtonWidth+titleButtonSpacing,
+		common.ScreenHeight-100,
+		titleButtonWidth,
+		60,
+		"GHOST",
+		common.MiddleFontSize,
+		buttonColor1,
+	)
+
+	g.challengeButton = newButton(
+		titleButtonX+2*(titleButtonWidth+titleButtonSpacing),
+		common.ScreenHeight-100,
+		titleButtonWidth,
+		60,
+		"CHALLENGE",
+		common.MiddleFontSize,
+		buttonColor1,
+	)
+
+	g.difficultyButton = newButton(
+		common.ScreenWidth/2-80,
+		8,
+		160,
+		32,
+		difficultyLabel(g.difficulty),
+		common.SmallFontSize,
+		buttonColor2,
+	)
+
+	g.profileButton = newButton(
+		common.ScreenWidth-168,
+		8,
+		160,
+		32,
+		"PROFILE",
+		common.SmallFontSize,
+		buttonColor2,
+	)
+
+	buttonWidth := 110
 	buttonHeight := 40
 	buttonY := common.ScreenHeight - 80
-	buttonSpacing := 20
+	buttonSpacing := 10
+	buttonX := (common.ScreenWidth - 5*buttonWidth - 4*buttonSpacing) / 2
 
 	g.dailyButton = newButton(
-		50,
+		buttonX,
 		buttonY,
 		buttonWidth,
 		buttonHeight,
@@ -179,7 +328,7 @@ func (g *Game) init() {
 	)
 
 	g.weeklyButton = newButton(
-		50+buttonWidth+buttonSpacing,
+		buttonX+buttonWidth+buttonSpacing,
 		buttonY,
 		buttonWidth,
 		buttonHeight,
@@ -189,7 +338,7 @@ func (g *Game) init() {
 	)
 
 	g.monthlyButton = newButton(
-		50+2*(buttonWidth+buttonSpacing),
+		buttonX+2*(buttonWidth+buttonSpacing),
 		buttonY,
 		buttonWidth,
 		buttonHeight,
@@ -198,8 +347,18 @@ func (g *Game) init() {
 		buttonColor2,
 	)
 
+	g.challengeRankingButton = newButton(
+		buttonX+3*(buttonWidth+buttonSpacing),
+		buttonY,
+		buttonWidth,
+		buttonHeight,
+		"CHALLENGE",
+		common.SmallFontSize,
+		buttonColor2,
+	)
+
 	g.backButton = newButton(
-		50+3*(buttonWidth+buttonSpacing),
+		buttonX+4*(buttonWidth+buttonSpacing),
 		buttonY,
 		buttonWidth,
 		buttonHeight,
@@ -208,6 +367,26 @@ func (g *Game) init() {
 		buttonColor2,
 	)
 
+	g.prevPageButton = newButton(
+		8,
+		rankingRowY(rankingPageSize/2)-30,
+		40,
+		40,
+		"<",
+		common.SmallFontSize,
+		buttonColor2,
+	)
+
+	g.nextPageButton = newButton(
+		common.ScreenWidth-48,
+		rankingRowY(rankingPageSize/2)-30,
+		40,
+		40,
+		">",
+		common.SmallFontSize,
+		buttonColor2,
+	)
+
 	g.submitScoreButton = newButton(
 		common.ScreenWidth/2-160,
 		common.ScreenHeight-250,
@@ -257,46 +436,86 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 }
 
 func (g *Game) Update() error {
+	for drained := false; !drained; {
+		select {
+		case player := <-g.registeredPlayers:
+			g.setPlayer(player)
+		default:
+			drained = true
+		}
+	}
 	switch g.mode {
 	case ModeTitle:
 		if g.rankingButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyR) {
 			g.rankingPeriod = "DAILY"
-			go g.fetchRanking()
+			g.rankingCourse = common.CourseRandom
+			g.resetRanking()
 			g.mode = ModeRanking
 			return nil
 		}
 
+		if g.ghostButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyG) {
+			// Race against today's #1, or fly alone if there is none yet.
+			g.startGame(common.CourseRandom, g.fetchTopScoreID())
+			return nil
+		}
+
+		if g.challengeButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyC) {
+			g.startGame(common.CourseChallenge, 0)
+			return nil
+		}
+
+		if g.profileButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyP) {
+			g.openProfile()
+			g.mode = ModeProfile
+			return nil
+		}
+
+		if step, ok := g.difficultyStep(); ok {
+			g.changeDifficulty(step)
+			return nil
+		}
+
 		if g.isKeyJustPressed() {
-			g.fetchToken()
-			g.obj = common.NewObject(
-				common.InitialX16,
-				common.InitialY16,
-				0,
-				g.pipeKey,
-			)
-			g.mode = ModeGame
+			g.startGame(common.CourseRandom, 0)
 		}
 	case ModeGame:
-		g.obj.X16 += common.DeltaX16
-		g.cameraX += common.DeltaCameraX
-		if g.isKeyJustPressed() {
+		if g.ghost != nil && !g.ghost.Obj.Hit() {
+			g.ghost.Update()
+		}
+		jump := g.isKeyJustPressed()
+		for drained := false; !drained; {
+			select {
+			case r := <-g.revealedPipes:
+				if r.token == g.token {
+					g.pendingPipes = append(g.pendingPipes, r.pipes)
+				}
+			default:
+				drained = true
+			}
+		}
+		g.addPendingPipes()
+		g.obj.Update(jump)
+		g.cameraX = cameraXOf(g.obj)
+		if score := g.obj.Score(); score > g.checkpointScore && score%common.CheckpointInterval == 0 {
+			g.checkpointScore = score
+			go g.reportCheckpoint(g.token, score)
+		}
+		if jump {
 			g.jumpHistory = append(g.jumpHistory, g.obj.X16)
-			g.obj.Vy16 = -common.VyLimit
 			if err := g.jumpPlayer.Rewind(); err != nil {
 				return err
 			}
 			g.jumpPlayer.Play()
 		}
-		g.obj.Y16 += g.obj.Vy16
-
-		// Gravity
-		g.obj.Vy16 += common.DeltaVy16
-		if g.obj.Vy16 > common.VyLimit {
-			g.obj.Vy16 = common.VyLimit
-		}
 
 		if g.obj.Hit() {
 			// log.Printf("debug jumpHistory: %v", g.jumpHistory)
+			if g.obj.HitsUnknownPipe() {
+				log.Printf("Reached pipes that were not revealed at score %d", g.obj.Score())
+				g.courseLost = true
+				g.errorMessage = "Course lost, check your connection"
+			}
 			if err := g.hitPlayer.Rewind(); err != nil {
 				return err
 			}
@@ -308,24 +527,14 @@ func (g *Game) Update() error {
 	case ModeGameOver:
 		g.gameoverCount++
 
-		// Input
-		runes := ebiten.AppendInputChars(nil)
-		for _, r := range runes {
-			if r != ' ' && len(g.playerName) < 10 {
-				g.playerName += string(r)
-			}
-		}
-
-		// Delete
-		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.playerName) > 0 {
-			g.playerName = g.playerName[:len(g.playerName)-1]
+		if !g.scoreSubmitted {
+			g.nameInput.Update()
 		}
-
-		playerName := g.playerName
-		if len(g.playerName) == 0 {
+		playerName := g.nameInput.Text()
+		if playerName == "" {
 			playerName = "NO NAME"
 		}
-		if g.submitScoreButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		if !g.courseLost && (g.submitScoreButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyEnter)) {
 			g.submitScore(playerName)
 			return nil
 		}
@@ -336,27 +545,305 @@ func (g *Game) Update() error {
 	case ModeRanking:
 		if g.dailyButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyD) {
 			g.rankingPeriod = "DAILY"
-			go g.fetchRanking()
+			g.rankingCourse = common.CourseRandom
+			g.resetRanking()
 		}
 		if g.weeklyButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyW) {
 			g.rankingPeriod = "WEEKLY"
-			go g.fetchRanking()
+			g.rankingCourse = common.CourseRandom
+			g.resetRanking()
 		}
 		if g.monthlyButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyM) {
 			g.rankingPeriod = "MONTHLY"
-			go g.fetchRanking()
+			g.rankingCourse = common.CourseRandom
+			g.resetRanking()
+		}
+		if g.challengeRankingButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyC) {
+			// Today's challenge course
+			g.rankingPeriod = "DAILY"
+			g.rankingCourse = common.CourseChallenge
+			g.resetRanking()
+		}
+		if step, ok := g.difficultyStep(); ok {
+			g.changeDifficulty(step)
+			g.resetRanking()
 		}
 		if g.backButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
 			g.mode = ModeTitle
 		}
+		g.prevPageButton.Enabled = len(g.rankingPrevs) > 0
+		g.nextPageButton.Enabled = g.rankingNext != ""
+		if g.prevPageButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyPageUp) {
+			g.prevRankingPage(false)
+			return nil
+		}
+		if g.nextPageButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyPageDown) {
+			g.nextRankingPage()
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+			if g.rankingCursor > 0 {
+				g.rankingCursor--
+			} else {
+				g.prevRankingPage(true)
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+			if g.rankingCursor < len(g.rankings)-1 {
+				g.rankingCursor++
+			} else {
+				g.nextRankingPage()
+			}
+		}
+		if i, ok := g.clickedRankingRow(); ok {
+			g.rankingCursor = i
+			g.startRankingReplay()
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			g.startRankingReplay()
+		}
+	case ModeProfile:
+		if step, ok := g.difficultyStep(); ok {
+			g.changeDifficulty(step)
+			g.openProfile()
+		}
+		if g.backButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			g.mode = ModeTitle
+			return nil
+		}
+		if g.fetchingProfile || g.profile == nil {
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyUp) && g.profileCursor > 0 {
+			g.profileCursor--
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyDown) && g.profileCursor < len(g.profile.RecentRuns)-1 {
+			g.profileCursor++
+		}
+		if i, ok := clickedRow(len(g.profile.RecentRuns), profileRowY); ok {
+			g.profileCursor = i
+			g.startReplay(g.profile.RecentRuns[i])
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && g.profileCursor < len(g.profile.RecentRuns) {
+			g.startReplay(g.profile.RecentRuns[g.profileCursor])
+		}
+	case ModeReplay:
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			g.exitReplay()
+			return nil
+		}
+		if g.fetchingReplay {
+			return nil
+		}
+		if g.replayer == nil {
+			// Failed to fetch the replay
+			g.exitReplay()
+			return nil
+		}
+		if g.obj.Hit() {
+			g.gameoverCount++
+			if g.gameoverCount > 30 && g.isKeyJustPressed() {
+				g.exitReplay()
+			}
+			return nil
+		}
+
+		jumped := g.replayer.Update()
+		g.cameraX = cameraXOf(g.obj)
+		if jumped {
+			if err := g.jumpPlayer.Rewind(); err != nil {
+				return err
+			}
+			g.jumpPlayer.Play()
+		}
+		if g.obj.Hit() {
+			if err := g.hitPlayer.Rewind(); err != nil {
+				return err
+			}
+			g.hitPlayer.Play()
+			g.gameoverCount = 0
+		}
 	}
 	return nil
 }
 
+// addPendingPipes adds the revealed pipes that follow the known ones, which may let later segments follow in turn.
+func (g *Game) addPendingPipes() {
+	for added := true; added; {
+		added = false
+		pending := g.pendingPipes[:0]
+		for _, pipes := range g.pendingPipes {
+			if g.obj.AddPipes(pipes) {
+				added = true
+				continue
+			}
+			pending = append(pending, pipes)
+		}
+		g.pendingPipes = pending
+	}
+}
+
+// startGame starts a session and plays it, or stays on the title screen with an error message if the session can't start.
+func (g *Game) startGame(course common.Course, ghostID int) {
+	g.rules = g.difficulty.Rules()
+	if err := g.fetchToken(course, ghostID); err != nil {
+		log.Printf("Failed to start game: %v", err)
+		g.errorMessage = "Failed to start game"
+		if errors.Is(err, errChallengeUnavailable) {
+			g.errorMessage = "Daily challenge unavailable"
+		}
+		return
+	}
+	g.errorMessage = ""
+	g.pendingPipes, g.courseLost = nil, false
+	g.obj = newCourseObject("", g.pipes, g.rules)
+	g.mode = ModeGame
+}
+
+// newCourseObject returns an Object of the course given by its pipe key, or by the pipes revealed so far if it has none.
+func newCourseObject(pipeKey string, pipes *common.PipeSegment, rules common.Rules) *common.Object {
+	if pipeKey != "" {
+		return common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey, rules)
+	}
+	obj := common.NewRevealedObject(common.InitialX16, common.InitialY16, 0, rules)
+	if pipes != nil {
+		obj.AddPipes(pipes)
+	}
+	return obj
+}
+
+// cameraXOf returns the camera position that keeps obj at the same place on the screen.
+// The camera follows the gopher because its speed depends on the difficulty.
+func cameraXOf(obj *common.Object) int {
+	return common.FloorDiv(obj.X16, common.Unit) + common.InitialCameraX
+}
+
+// difficultyStep returns the direction to change the difficulty in,
+// which is the next one for a click on the difficulty button.
+func (g *Game) difficultyStep() (int, bool) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+		return -1, true
+	}
+	if g.difficultyButton.IsClicked() || inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		return 1, true
+	}
+	return 0, false
+}
+
+func (g *Game) changeDifficulty(step int) {
+	n := len(common.Difficulties)
+	for i, d := range common.Difficulties {
+		if d == g.difficulty {
+			g.difficulty = common.Difficulties[((i+step)%n+n)%n]
+			break
+		}
+	}
+	g.difficultyButton.Text = difficultyLabel(g.difficulty)
+}
+
+func difficultyLabel(d common.Difficulty) string {
+	return "< " + string(d) + " >"
+}
+
+// rankingPageSize is the number of rows on a page of the ranking screen.
+const rankingPageSize = 10
+
+// resetRanking shows the first page of the selected board.
+func (g *Game) resetRanking() {
+	g.rankingPage = ""
+	g.rankingPrevs = nil
+	g.rankingNext = ""
+	go g.fetchRanking("", false)
+}
+
+func (g *Game) nextRankingPage() {
+	if g.fetchingRanking || g.rankingNext == "" {
+		return
+	}
+	g.rankingPrevs = append(g.rankingPrevs, g.rankingPage)
+	g.rankingPage = g.rankingNext
+	go g.fetchRanking(g.rankingPage, false)
+}
+
+// prevRankingPage goes back a page. The last row is selected if selectLast is true.
+func (g *Game) prevRankingPage(selectLast bool) {
+	if g.fetchingRanking || len(g.rankingPrevs) == 0 {
+		return
+	}
+	g.rankingPage = g.rankingPrevs[len(g.rankingPrevs)-1]
+	g.rankingPrevs = g.rankingPrevs[:len(g.rankingPrevs)-1]
+	go g.fetchRanking(g.rankingPage, selectLast)
+}
+
+// rankingRowY returns the y of the i-th row on the ranking screen.
+func rankingRowY(i int) int {
+	return 100 + i*30
+}
+
+// clickedRankingRow returns the index of the ranking row just clicked or touched.
+func (g *Game) clickedRankingRow() (int, bool) {
+	if g.fetchingRanking {
+		return 0, false
+	}
+	return clickedRow(min(len(g.rankings), rankingPageSize), rankingRowY)
+}
+
+// clickedRow returns the index of the row just clicked or touched among n rows placed at rowY.
+func clickedRow(n int, rowY func(int) int) (int, bool) {
+	var ys []int
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		_, y := ebiten.CursorPosition()
+		ys = append(ys, y)
+	}
+	for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+		_, y := ebiten.TouchPosition(id)
+		ys = append(ys, y)
+	}
+	for _, y := range ys {
+		for i := range n {
+			if rowY(i) <= y && y < rowY(i+1) {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// startRankingReplay plays the replay of the selected ranking row.
+func (g *Game) startRankingReplay() {
+	if g.fetchingRanking || g.rankingCursor >= len(g.rankings) {
+		return
+	}
+	g.startReplay(g.rankings[g.rankingCursor])
+}
+
+// startReplay plays the replay of the score and returns to the current screen when it ends.
+func (g *Game) startReplay(score *common.Score) {
+	g.replayScore = score
+	g.replayer = nil
+	g.obj = nil
+	g.cameraX = common.InitialCameraX
+	g.cameraY = common.InitialCameraY
+	g.gameoverCount = 0
+	g.fetchingReplay = true
+	go g.fetchReplay(g.replayScore.ID)
+	g.replayReturnMode = g.mode
+	g.mode = ModeReplay
+}
+
+func (g *Game) exitReplay() {
+	g.replayer = nil
+	g.obj = nil
+	g.cameraX = common.InitialCameraX
+	g.cameraY = common.InitialCameraY
+	g.mode = g.replayReturnMode
+}
+
 func (g *Game) drawRanking(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{0x40, 0x40, 0x60, 0xff})
 
 	title := fmt.Sprintf("%s RANKING", g.rankingPeriod)
+	if g.rankingCourse == common.CourseChallenge {
+		title = "CHALLENGE RANKING"
+	}
 	op := &text.DrawOptions{}
 	op.GeoM.Translate(common.ScreenWidth/2, 50)
 	op.ColorScale.ScaleWithColor(color.White)
@@ -380,31 +867,46 @@ func (g *Game) drawRanking(screen *ebiten.Image) {
 		}, op)
 	} else {
 		for i, score := range g.rankings {
-			// Top 10
-			if i > 9 {
+			if i >= rankingPageSize {
 				break
 			}
 
-			y := 100 + i*30
-			rankText := fmt.Sprintf("%2d. %-10s %4d", score.Rank, score.DisplayName, score.Score)
+			y := rankingRowY(i)
+			rankText := fmt.Sprintf("%4d. %-10s %4d", score.Rank, score.DisplayName, score.Score)
 
 			op := &text.DrawOptions{}
 			op.GeoM.Translate(common.ScreenWidth/2, float64(y))
-			op.ColorScale.ScaleWithColor(color.White)
+			if i == g.rankingCursor {
+				op.ColorScale.ScaleWithColor(color.RGBA{0xff, 0xe0, 0x60, 0xff})
+			} else {
+				op.ColorScale.ScaleWithColor(color.White)
+			}
 			op.PrimaryAlign = text.AlignCenter
-			text.Draw(screen, rankText, &text.GoTextFace{
-				Source: arcadeFaceSource,
-				Size:   common.FontSize,
-			}, op)
+			text.Draw(screen, rankText, nameFace(common.FontSize), op)
 		}
 	}
 
 	g.dailyButton.Draw(screen)
 	g.weeklyButton.Draw(screen)
 	g.monthlyButton.Draw(screen)
+	g.challengeRankingButton.Draw(screen)
 	g.backButton.Draw(screen)
+	g.difficultyButton.Draw(screen)
+	g.prevPageButton.Draw(screen)
+	g.nextPageButton.Draw(screen)
 
-	guide := "D: Daily  W: Weekly  M: Monthly  ESC: Back"
+	if len(g.rankingPrevs) > 0 || g.rankingNext != "" {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(common.ScreenWidth/2, 86)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, fmt.Sprintf("PAGE %d", len(g.rankingPrevs)+1), &text.GoTextFace{
+			Source: arcadeFaceSource,
+			Size:   common.SmallFontSize,
+		}, op)
+	}
+
+	guide := "D/W/M/C: Board  PGUP/PGDN: Page  ENTER: Replay"
 	op = &text.DrawOptions{}
 	op.GeoM.Translate(common.ScreenWidth/2, common.ScreenHeight-30)
 	op.ColorScale.ScaleWithColor(color.White)
@@ -415,16 +917,95 @@ func (g *Game) drawRanking(screen *ebiten.Image) {
 	}, op)
 }
 
+// openProfile shows the profile of the player on the selected difficulty.
+func (g *Game) openProfile() {
+	g.profileCursor = 0
+	if g.fetchingProfile {
+		return
+	}
+	g.fetchingProfile = true
+	go g.fetchProfile(g.playerID)
+}
+
+// profileRowY returns the y of the i-th recent run on the profile screen.
+func profileRowY(i int) int {
+	return 210 + i*20
+}
+
+func (g *Game) drawProfile(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0x40, 0x40, 0x60, 0xff})
+	g.difficultyButton.Draw(screen)
+	g.backButton.Draw(screen)
+
+	drawText := func(s string, y int, size float64, clr color.Color) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(common.ScreenWidth/2, float64(y))
+		op.ColorScale.ScaleWithColor(clr)
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, s, nameFace(size), op)
+	}
+	drawText("PROFILE", 50, common.TitleFontSize, color.White)
+	drawText("ENTER: Replay  ESC: Back", common.ScreenHeight-30, common.SmallFontSize, color.White)
+
+	switch {
+	case g.fetchingProfile:
+		drawText("Loading...", 150, common.FontSize, color.White)
+		return
+	case g.profile == nil:
+		drawText("No data", 150, common.FontSize, color.White)
+		return
+	}
+
+	p := g.profile
+	name := p.DisplayName
+	if name == "" {
+		name = "NO NAME"
+	}
+	drawText(name, 100, common.FontSize, color.White)
+	drawText(fmt.Sprintf("PLAYS %d  AVERAGE %.1f", p.Plays, p.AverageScore), 130, common.SmallFontSize, color.White)
+	var bests string
+	for _, period := range []struct{ key, label string }{
+		{"DAILY", "TODAY"}, {"WEEKLY", "WEEK"}, {"MONTHLY", "MONTH"}, {"ALL_TIME", "ALL"},
+	} {
+		best := "-"
+		if s := p.Bests[period.key]; s != nil {
+			best = fmt.Sprint(s.Score)
+		}
+		bests += fmt.Sprintf("  %s %s", period.label, best)
+	}
+	drawText("BEST"+bests, 155, common.SmallFontSize, color.White)
+
+	if len(p.RecentRuns) == 0 {
+		drawText("No runs yet", profileRowY(0), common.MiddleFontSize, color.White)
+		return
+	}
+	drawText("RECENT RUNS", profileRowY(0)-25, common.SmallFontSize, color.White)
+	for i, s := range p.RecentRuns {
+		clr := color.Color(color.White)
+		if i == g.profileCursor {
+			clr = color.RGBA{0xff, 0xe0, 0x60, 0xff}
+		}
+		drawText(fmt.Sprintf("%s %4d", s.CreatedAt.Local().Format("01/02 15:04"), s.Score), profileRowY(i), common.MiddleFontSize, clr)
+	}
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	if g.mode == ModeRanking {
 		g.drawRanking(screen)
 		return
 	}
+	if g.mode == ModeProfile {
+		g.drawProfile(screen)
+		return
+	}
 
 	screen.Fill(color.RGBA{0x80, 0xa0, 0xc0, 0xff})
 	g.drawTiles(screen)
-	if g.mode != ModeTitle {
-		g.drawGopher(screen)
+	if g.mode != ModeTitle && g.ghost != nil {
+		g.drawGopher(screen, g.ghost.Obj, 0.4)
+	}
+	if g.mode != ModeTitle && g.obj != nil {
+		g.drawGopher(screen, g.obj, 1)
 	}
 
 	var titleTexts string
@@ -432,19 +1013,30 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	switch g.mode {
 	case ModeTitle:
 		titleTexts = "FLAPPY GOPHER\nWITH RANKING"
-		texts = "\n\n\n\nPRESS SPACE KEY\n\nOR A/B BUTTON\n\nOR TOUCH SCREEN\n\nPRESS R FOR RANKING"
+		texts = "\n\n\n\nPRESS SPACE KEY\n\nOR A/B BUTTON\n\nOR TOUCH SCREEN\n\nR:RANK G:GHOST C:CHALLENGE"
 		g.rankingButton.Draw(screen)
+		g.ghostButton.Draw(screen)
+		g.challengeButton.Draw(screen)
+		g.difficultyButton.Draw(screen)
+		g.profileButton.Draw(screen)
 	case ModeGameOver:
-		if g.scoreSubmitted {
+		if g.scoreSubmitted && len(g.aroundScores) > 0 {
+			texts = "\nSCORE SUBMITTED!\n\n\n\n\n\n\n\n\n\n\n\nPRESS KEY TO CONTINUE"
+			g.drawAround(screen)
+		} else if g.scoreSubmitted {
 			texts = "\nSCORE SUBMITTED!\n\n\n\n\n\n\n\nPRESS KEY TO CONTINUE"
 		} else {
-			cursor := " "
-			if g.gameoverCount%30 < 15 {
-				cursor = "_"
-			}
-			texts = "\nENTER OR SUBMIT YOUR NAME:\n\n" + g.playerName + cursor + "\n\n\n\n\n\nPRESS KEY TO CONTINUE"
+			texts = "\nENTER OR SUBMIT YOUR NAME:\n\n\n\n\n\n\n\nPRESS KEY TO CONTINUE"
+			g.nameInput.Draw(screen, common.ScreenWidth/2, 3*common.TitleFontSize+3*common.FontSize, nameFace(common.FontSize))
 			g.submitScoreButton.Draw(screen)
 		}
+	case ModeReplay:
+		texts = fmt.Sprintf("\nREPLAY: %s %d", g.replayScore.DisplayName, g.replayScore.Score)
+		if g.fetchingReplay {
+			texts += "\n\nLOADING..."
+		} else if g.obj != nil && g.obj.Hit() {
+			texts += "\n\n\n\n\n\n\n\nPRESS KEY TO RETURN"
+		}
 	}
 
 	op := &text.DrawOptions{}
@@ -462,10 +1054,18 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	op.ColorScale.ScaleWithColor(color.White)
 	op.LineSpacing = common.FontSize
 	op.PrimaryAlign = text.AlignCenter
-	text.Draw(screen, texts, &text.GoTextFace{
-		Source: arcadeFaceSource,
-		Size:   common.FontSize,
-	}, op)
+	text.Draw(screen, texts, nameFace(common.FontSize), op)
+
+	if (g.mode == ModeTitle || g.mode == ModeGameOver && !g.scoreSubmitted) && g.errorMessage != "" {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(common.ScreenWidth/2, 3*common.TitleFontSize+5*common.FontSize)
+		op.ColorScale.ScaleWithColor(color.RGBA{0xff, 0x80, 0x80, 0xff})
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, g.errorMessage, &text.GoTextFace{
+			Source: arcadeFaceSource,
+			Size:   common.SmallFontSize,
+		}, op)
+	}
 
 	if g.mode == ModeTitle {
 		const msg = "Go Gopher by Renee French is\nlicenced under CC BY 3.0."
@@ -494,9 +1094,53 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}, op)
 	}
 
+	if g.ghostScore != nil && (g.mode == ModeGame || g.mode == ModeGameOver) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(common.ScreenWidth, common.FontSize*1.5)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.ColorScale.ScaleAlpha(0.6)
+		op.PrimaryAlign = text.AlignEnd
+		text.Draw(screen, fmt.Sprintf("GHOST %s %04d", g.ghostScore.DisplayName, g.ghost.Obj.Score()), nameFace(common.SmallFontSize), op)
+	}
+
 	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f", ebiten.ActualTPS()))
 }
 
+// drawAround draws the rank of the submitted score and its neighbours on today's board.
+func (g *Game) drawAround(screen *ebiten.Image) {
+	const (
+		top        = 170
+		rowsTop    = top + 36
+		lineHeight = 16
+	)
+	panel := ebiten.NewImage(common.ScreenWidth-160, 36+len(g.aroundScores)*lineHeight+8)
+	panel.Fill(color.RGBA{0x40, 0x40, 0x60, 0xc0})
+	panelOp := &ebiten.DrawImageOptions{}
+	panelOp.GeoM.Translate(80, top-8)
+	screen.DrawImage(panel, panelOp)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(common.ScreenWidth/2, top)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, fmt.Sprintf("TODAY #%d", g.aroundRank), &text.GoTextFace{
+		Source: arcadeFaceSource,
+		Size:   common.FontSize,
+	}, op)
+
+	for i, score := range g.aroundScores {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(common.ScreenWidth/2, float64(rowsTop+i*lineHeight))
+		if score.ID == g.aroundID {
+			op.ColorScale.ScaleWithColor(color.RGBA{0xff, 0xe0, 0x60, 0xff})
+		} else {
+			op.ColorScale.ScaleWithColor(color.White)
+		}
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, fmt.Sprintf("%5d. %-10s %4d", score.Rank, score.DisplayName, score.Score), nameFace(common.SmallFontSize), op)
+	}
+}
+
 func (g *Game) drawTiles(screen *ebiten.Image) {
 	const (
 		nx           = common.ScreenWidth / common.TileSize
@@ -514,6 +1158,9 @@ func (g *Game) drawTiles(screen *ebiten.Image) {
 		screen.DrawImage(tilesImage.SubImage(image.Rect(0, 0, common.TileSize, common.TileSize)).(*ebiten.Image), op)
 
 		// pipe
+		if g.obj == nil {
+			continue
+		}
 		if tileY, ok := g.obj.PipeAt(common.FloorDiv(g.cameraX, common.TileSize) + i); ok {
 			for j := 0; j < tileY; j++ {
 				op.GeoM.Reset()
@@ -529,12 +1176,12 @@ func (g *Game) drawTiles(screen *ebiten.Image) {
 				}
 				screen.DrawImage(tilesImage.SubImage(r).(*ebiten.Image), op)
 			}
-			for j := tileY + common.PipeGapY; j < common.ScreenHeight/common.TileSize-1; j++ {
+			for j := tileY + g.obj.Rules.PipeGapY; j < common.ScreenHeight/common.TileSize-1; j++ {
 				op.GeoM.Reset()
 				op.GeoM.Translate(float64(i*common.TileSize-common.FloorMod(g.cameraX, common.TileSize)),
 					float64(j*common.TileSize-common.FloorMod(g.cameraY, common.TileSize)))
 				var r image.Rectangle
-				if j == tileY+common.PipeGapY {
+				if j == tileY+g.obj.Rules.PipeGapY {
 					r = image.Rect(pipeTileSrcX, pipeTileSrcY, pipeTileSrcX+common.PipeWidth, pipeTileSrcY+common.TileSize)
 				} else {
 					r = image.Rect(pipeTileSrcX, pipeTileSrcY+common.TileSize, pipeTileSrcX+common.PipeWidth, pipeTileSrcY+common.TileSize+common.TileSize)
@@ -545,13 +1192,15 @@ func (g *Game) drawTiles(screen *ebiten.Image) {
 	}
 }
 
-func (g *Game) drawGopher(screen *ebiten.Image) {
+// drawGopher draws the gopher of obj. A ghost is drawn translucent with alpha < 1.
+func (g *Game) drawGopher(screen *ebiten.Image, obj *common.Object, alpha float32) {
 	op := &ebiten.DrawImageOptions{}
 	w, h := gopherImage.Bounds().Dx(), gopherImage.Bounds().Dy()
 	op.GeoM.Translate(-float64(w)/2.0, -float64(h)/2.0)
-	op.GeoM.Rotate(float64(g.obj.Vy16) / 96.0 * math.Pi / 6)
+	op.GeoM.Rotate(float64(obj.Vy16) / float64(obj.Rules.VyLimit) * math.Pi / 6)
 	op.GeoM.Translate(float64(w)/2.0, float64(h)/2.0)
-	op.GeoM.Translate(float64(g.obj.X16/16.0)-float64(g.cameraX), float64(g.obj.Y16/16.0)-float64(g.cameraY))
+	op.GeoM.Translate(float64(obj.X16/16.0)-float64(g.cameraX), float64(obj.Y16/16.0)-float64(g.cameraY))
+	op.ColorScale.ScaleAlpha(alpha)
 	op.Filter = ebiten.FilterLinear
 	screen.DrawImage(gopherImage, op)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"syscall/js"
+
+	"github.com/ponyo877/flappy-ranking/common"
+)
+
+// playerKeyItem is the localStorage item that keeps the player key of this device.
+const playerKeyItem = "flappy-ranking.playerKey"
+
+// loadPlayerKey returns the player key of this device, generating and storing it on the first run.
+// Without localStorage the key only lasts until the page is closed.
+func loadPlayerKey() string {
+	storage := js.Global().Get("localStorage")
+	if storage.Truthy() {
+		if item := storage.Call("getItem", playerKeyItem); item.Type() == js.TypeString {
+			return item.String()
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Failed to generate player key: %v", err)
+		return ""
+	}
+	key := base64.RawURLEncoding.EncodeToString(b)
+	if storage.Truthy() {
+		storage.Call("setItem", playerKeyItem, key)
+	}
+	return key
+}
+
+// playerProfile is the record of the player of this device on a board.
+type playerProfile struct {
+	DisplayName  string
+	Plays        int
+	AverageScore float64
+	// Bests holds the best score of each period, or nil if the player has not played in it.
+	Bests map[string]*common.Score
+	// RecentRuns are the latest scores, newest first.
+	RecentRuns []*common.Score
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"sync"
+	"syscall/js"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// TextInput is a single-line text field that edits runes, so that names in any script can be typed,
+// pasted and erased one character at a time.
+type TextInput struct {
+	MaxLength int
+	// Accept filters the runes that can be typed or pasted, in addition to control characters.
+	Accept func(r rune) bool
+
+	runes  []rune
+	cursor int // index of the rune before which text is inserted
+	frames int
+
+	mu     sync.Mutex
+	pasted []string // text read from the clipboard, inserted on the next Update
+}
+
+func newTextInput(maxLength int, accept func(r rune) bool) *TextInput {
+	return &TextInput{MaxLength: maxLength, Accept: accept}
+}
+
+func (t *TextInput) Text() string {
+	return string(t.runes)
+}
+
+// SetText replaces the text and moves the cursor to its end.
+func (t *TextInput) SetText(s string) {
+	t.runes = nil
+	t.cursor = 0
+	t.insert(s)
+}
+
+func (t *TextInput) Update() {
+	t.frames++
+
+	shortcut := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	if !shortcut {
+		t.insert(string(ebiten.AppendInputChars(nil)))
+	}
+	t.mu.Lock()
+	pasted := t.pasted
+	t.pasted = nil
+	t.mu.Unlock()
+	for _, s := range pasted {
+		t.insert(s)
+	}
+
+	switch {
+	case shortcut && inpututil.IsKeyJustPressed(ebiten.KeyV):
+		t.paste()
+	case repeatingKeyPressed(ebiten.KeyBackspace) && t.cursor > 0:
+		t.runes = append(t.runes[:t.cursor-1], t.runes[t.cursor:]...)
+		t.cursor--
+	case repeatingKeyPressed(ebiten.KeyDelete) && t.cursor < len(t.runes):
+		t.runes = append(t.runes[:t.cursor], t.runes[t.cursor+1:]...)
+	case repeatingKeyPressed(ebiten.KeyLeft) && t.cursor > 0:
+		t.cursor--
+	case repeatingKeyPressed(ebiten.KeyRight) && t.cursor < len(t.runes):
+		t.cursor++
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		t.cursor = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		t.cursor = len(t.runes)
+	default:
+		return
+	}
+	// Keep the cursor visible while editing.
+	t.frames = 0
+}
+
+// insert inserts the accepted runes of s at the cursor, up to MaxLength runes in total.
+func (t *TextInput) insert(s string) {
+	for _, r := range s {
+		if len(t.runes) >= t.MaxLength {
+			return
+		}
+		if unicode.IsControl(r) || (t.Accept != nil && !t.Accept(r)) {
+			continue
+		}
+		t.runes = append(t.runes[:t.cursor], append([]rune{r}, t.runes[t.cursor:]...)...)
+		t.cursor++
+	}
+}
+
+// paste reads the clipboard of the browser, which resolves asynchronously.
+func (t *TextInput) paste() {
+	clipboard := js.Global().Get("navigator").Get("clipboard")
+	if !clipboard.Truthy() {
+		return
+	}
+	var then, catch js.Func
+	release := func() {
+		then.Release()
+		catch.Release()
+	}
+	then = js.FuncOf(func(this js.Value, args []js.Value) any {
+		defer release()
+		// Only the first line fits in a single-line field.
+		s, _, _ := strings.Cut(args[0].String(), "\n")
+		t.mu.Lock()
+		t.pasted = append(t.pasted, s)
+		t.mu.Unlock()
+		return nil
+	})
+	catch = js.FuncOf(func(this js.Value, args []js.Value) any {
+		// The player denied the permission to read the clipboard.
+		defer release()
+		return nil
+	})
+	clipboard.Call("readText").Call("then", then).Call("catch", catch)
+}
+
+// Draw draws the text centered at x with its top at y and a blinking cursor.
+func (t *TextInput) Draw(screen *ebiten.Image, x, y float64, face text.Face) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, t.Text(), face, op)
+
+	if t.frames%60 >= 30 {
+		return
+	}
+	width, _ := text.Measure(t.Text(), face, 0)
+	before, _ := text.Measure(string(t.runes[:t.cursor]), face, 0)
+	m := face.Metrics()
+	cursorX := x - width/2 + before
+	vector.DrawFilledRect(screen, float32(cursorX), float32(y), 2, float32(m.HAscent+m.HDescent), color.White, false)
+}
+
+// repeatingKeyPressed reports whether the key is just pressed or held long enough to repeat.
+func repeatingKeyPressed(key ebiten.Key) bool {
+	const (
+		delay    = 30
+		interval = 3
+	)
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d >= delay && (d-delay)%interval == 0)
+}
@@ -0,0 +1,18 @@
+package common
+
+// Board identifies a leaderboard. Scores are ranked separately per course and difficulty.
+type Board struct {
+	Course     Course
+	Difficulty Difficulty
+}
+
+func NewBoard(course Course, difficulty Difficulty) Board {
+	return Board{
+		Course:     course,
+		Difficulty: difficulty,
+	}
+}
+
+func (b Board) IsValid() bool {
+	return b.Course.IsValid() && b.Difficulty.IsValid()
+}
@@ -0,0 +1,18 @@
+package common
+
+// Course is the kind of course a session flies. Each course but CourseGhost has its own leaderboard.
+type Course string
+
+const (
+	// CourseRandom is a fresh random course for every session.
+	CourseRandom Course = "RANDOM"
+	// CourseChallenge is the daily challenge course shared by every player of the day.
+	CourseChallenge Course = "CHALLENGE"
+	// CourseGhost is the course of a replay raced as a ghost. It is known in advance, so it is not ranked.
+	CourseGhost Course = "GHOST"
+)
+
+// IsValid reports whether the course has a leaderboard.
+func (c Course) IsValid() bool {
+	return c == CourseRandom || c == CourseChallenge
+}
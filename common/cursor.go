@@ -0,0 +1,20 @@
+package common
+
+// ScoreCursor points at the last score of a leaderboard page. The next page starts after it
+// in the order of the leaderboard: higher scores first, and older scores first among ties.
+type ScoreCursor struct {
+	Score int
+	ID    int
+}
+
+func NewScoreCursor(score *Score) *ScoreCursor {
+	return &ScoreCursor{
+		Score: score.Score,
+		ID:    score.ID,
+	}
+}
+
+// Before reports whether the score comes after the cursor on the leaderboard.
+func (c *ScoreCursor) Before(score int, id int) bool {
+	return score < c.Score || (score == c.Score && id > c.ID)
+}
@@ -0,0 +1,24 @@
+package common
+
+// Difficulty selects the Rules a session is played with. Each difficulty has its own leaderboard.
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "EASY"
+	DifficultyNormal Difficulty = "NORMAL"
+	DifficultyHard   Difficulty = "HARD"
+)
+
+// Difficulties lists the difficulties in the order they are offered to players.
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyNormal, DifficultyHard}
+
+func (d Difficulty) IsValid() bool {
+	_, ok := LookupRules(RulesVersionCurrent, d)
+	return ok
+}
+
+// Rules returns the current rules of the difficulty, which must be valid.
+func (d Difficulty) Rules() Rules {
+	rules, _ := LookupRules(RulesVersionCurrent, d)
+	return rules
+}
@@ -11,37 +11,131 @@ type Object struct {
 	Y16  int
 	Vy16 int
 
-	// Pipes
-	PipeTileYs []int
+	Rules Rules
+
+	// Pipes are drawn from a ChaCha8 stream seeded by the pipe key.
+	// pipeTileYs caches the heights drawn so far, or holds the ones added by AddPipes if there is no pipe key.
+	pipeRand   *rand.Rand
+	pipeTileYs []int
+}
+
+// PipeSegment is a run of consecutive pipe heights of a course, starting at the From-th pipe.
+type PipeSegment struct {
+	From   int
+	TileYs []int
+}
+
+// CoursePipes returns the heights of the pipes from the from-th up to the to-th of the course of the pipe key.
+// Heights don't depend on the rules.
+func CoursePipes(pipeKey string, from, to int) *PipeSegment {
+	o := NewObject(0, 0, 0, pipeKey, Rules{})
+	segment := &PipeSegment{From: from}
+	for idx := from; idx <= to; idx++ {
+		y, _ := o.pipeTileY(idx)
+		segment.TileYs = append(segment.TileYs, y)
+	}
+	return segment
 }
 
-func NewObject(initX16, initY16, initVy16 int, pipeKey string) *Object {
-	pipeTileYs := make([]int, 256)
+func NewObject(initX16, initY16, initVy16 int, pipeKey string, rules Rules) *Object {
 	var seed [32]byte
 	pipeKeyBytes := []byte(pipeKey)
 	copy(seed[:], pipeKeyBytes)
 
-	r := rand.New(rand.NewChaCha8(seed))
-	for i := range pipeTileYs {
-		pipeTileYs[i] = r.IntN(6) + 2
+	return &Object{
+		X16:      initX16,
+		Y16:      initY16,
+		Vy16:     initVy16,
+		Rules:    rules,
+		pipeRand: rand.New(rand.NewChaCha8(seed)),
 	}
+}
+
+// NewRevealedObject returns an Object that is not given the pipe key of its course.
+// Its pipes are added by AddPipes as the server reveals them, and it flies like the Object of the pipe key
+// as long as they are added before the gopher reaches them.
+func NewRevealedObject(initX16, initY16, initVy16 int, rules Rules) *Object {
 	return &Object{
-		X16:        initX16,
-		Y16:        initY16,
-		Vy16:       initVy16,
-		PipeTileYs: pipeTileYs,
+		X16:   initX16,
+		Y16:   initY16,
+		Vy16:  initVy16,
+		Rules: rules,
+	}
+}
+
+// AddPipes adds the heights of the segment to the ones known so far, keeping those already known.
+// It reports false, adding nothing, if the segment starts after the next unknown pipe.
+func (o *Object) AddPipes(segment *PipeSegment) bool {
+	if segment.From > len(o.pipeTileYs) {
+		return false
+	}
+	if known := len(o.pipeTileYs) - segment.From; known < len(segment.TileYs) {
+		o.pipeTileYs = append(o.pipeTileYs, segment.TileYs[known:]...)
+	}
+	return true
+}
+
+// pipeTileY returns the height of the idx-th pipe. The course never repeats:
+// heights are drawn from the stream on demand, so they only depend on the pipe key and idx.
+// Without a pipe key, only the heights added by AddPipes are known.
+func (o *Object) pipeTileY(idx int) (int, bool) {
+	for o.pipeRand != nil && len(o.pipeTileYs) <= idx {
+		o.pipeTileYs = append(o.pipeTileYs, o.pipeRand.IntN(6)+2)
+	}
+	if idx >= len(o.pipeTileYs) {
+		return 0, false
+	}
+	return o.pipeTileYs[idx], true
+}
+
+// Update advances the gopher by one frame. It jumps at the new position if jump is true.
+func (o *Object) Update(jump bool) {
+	o.X16 += o.Rules.DeltaX16
+	if jump {
+		o.Vy16 = -o.Rules.VyLimit
+	}
+	o.Y16 += o.Vy16
+
+	// Gravity
+	o.Vy16 += o.Rules.DeltaVy16
+	if o.Vy16 > o.Rules.VyLimit {
+		o.Vy16 = o.Rules.VyLimit
 	}
 }
 
 func (o *Object) PipeAt(tileX int) (tileY int, ok bool) {
+	idx, ok := o.pipeIndex(tileX)
+	if !ok {
+		return 0, false
+	}
+	return o.pipeTileY(idx)
+}
+
+// pipeIndex returns the index of the pipe at tileX, or false if there is no pipe there.
+func (o *Object) pipeIndex(tileX int) (int, bool) {
 	if (tileX - PipeStartOffsetX) <= 0 {
 		return 0, false
 	}
-	if FloorMod(tileX-PipeStartOffsetX, PipeIntervalX) != 0 {
+	if FloorMod(tileX-PipeStartOffsetX, o.Rules.PipeIntervalX) != 0 {
 		return 0, false
 	}
-	idx := FloorDiv(tileX-PipeStartOffsetX, PipeIntervalX)
-	return o.PipeTileYs[idx%len(o.PipeTileYs)], true
+	return FloorDiv(tileX-PipeStartOffsetX, o.Rules.PipeIntervalX), true
+}
+
+// HitsUnknownPipe reports whether the gopher reaches a pipe whose height has not been added,
+// which Hit takes for a wall since the gap could be anywhere.
+func (o *Object) HitsUnknownPipe() bool {
+	x0, _, x1, _ := o.bounds()
+	for x := FloorDiv(x0-PipeWidth, TileSize); x <= FloorDiv(x1, TileSize); x++ {
+		idx, ok := o.pipeIndex(x)
+		if !ok || x0 >= x*TileSize+PipeWidth || x1 < x*TileSize {
+			continue
+		}
+		if _, known := o.pipeTileY(idx); !known {
+			return true
+		}
+	}
+	return false
 }
 
 func (o *Object) Score() int {
@@ -49,27 +143,32 @@ func (o *Object) Score() int {
 	if (x - PipeStartOffsetX) <= 0 {
 		return 0
 	}
-	return FloorDiv(x-PipeStartOffsetX, PipeIntervalX)
+	return FloorDiv(x-PipeStartOffsetX, o.Rules.PipeIntervalX)
 }
 
-func (o *Object) Hit() bool {
-	const (
-		gopherWidth  = 30
-		gopherHeight = 60
-	)
+// bounds returns the hit box of the gopher in pixels.
+func (o *Object) bounds() (x0, y0, x1, y1 int) {
+	gopherWidth, gopherHeight := o.Rules.GopherWidth, o.Rules.GopherHeight
 	// w, h := gopherImage.Bounds().Dx(), gopherImage.Bounds().Dy()
 	w, h := 60, 75
 
-	x0 := FloorDiv(o.X16, Unit) + (w-gopherWidth)/2
-	y0 := FloorDiv(o.Y16, Unit) + (h-gopherHeight)/2
-	x1 := x0 + gopherWidth
-	y1 := y0 + gopherHeight
+	x0 = FloorDiv(o.X16, Unit) + (w-gopherWidth)/2
+	y0 = FloorDiv(o.Y16, Unit) + (h-gopherHeight)/2
+	return x0, y0, x0 + gopherWidth, y0 + gopherHeight
+}
+
+func (o *Object) Hit() bool {
+	gopherWidth := o.Rules.GopherWidth
+	x0, y0, x1, y1 := o.bounds()
 	if y0 < -TileSize*4 {
 		return true
 	}
 	if y1 >= ScreenHeight-TileSize {
 		return true
 	}
+	if o.HitsUnknownPipe() {
+		return true
+	}
 	xMin := FloorDiv(x0-PipeWidth, TileSize)
 	xMax := FloorDiv(x0+gopherWidth, TileSize)
 	for x := xMin; x <= xMax; x++ {
@@ -86,21 +185,39 @@ func (o *Object) Hit() bool {
 		if y0 < y*TileSize {
 			return true
 		}
-		if y1 >= (y+PipeGapY)*TileSize {
+		if y1 >= (y+o.Rules.PipeGapY)*TileSize {
 			return true
 		}
 	}
 	return false
 }
 
+// PipeClearance returns the distance in pixels between the gopher and the nearest edge of the gap
+// of the pipe it is flying through, or false if it is not between pipes. It is negative if the gopher hits the pipe.
+func (o *Object) PipeClearance() (int, bool) {
+	x0, y0, x1, y1 := o.bounds()
+	clearance, ok := 0, false
+	for x := FloorDiv(x0-PipeWidth, TileSize); x <= FloorDiv(x1, TileSize); x++ {
+		y, found := o.PipeAt(x)
+		if !found || x0 >= x*TileSize+PipeWidth || x1 < x*TileSize {
+			continue
+		}
+		c := min(y0-y*TileSize, (y+o.Rules.PipeGapY)*TileSize-1-y1)
+		if !ok || c < clearance {
+			clearance, ok = c, true
+		}
+	}
+	return clearance, ok
+}
+
 func (o *Object) IsValidTimeDiff(startTime, endTime time.Time) bool {
 	diffSecond := int(endTime.Sub(startTime).Seconds())
 	// firstPipeDistance := PipeStartOffsetX * TileSize * Unit
-	gameSec60FPS := o.X16 / DeltaX16 / 60
+	gameSec60FPS := o.X16 / o.Rules.DeltaX16 / 60
 
 	// initTime := firstPipeDistance / DeltaX16 / 60
 	initTime := 0
-	intervalSec60FPS := PipeIntervalX * TileSize * Unit / DeltaX16 / 60
+	intervalSec60FPS := o.Rules.PipeIntervalX * TileSize * Unit / o.Rules.DeltaX16 / 60
 
 	// 60FPS Time
 	minTime := gameSec60FPS + initTime
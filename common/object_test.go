@@ -0,0 +1,122 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObject_PipeAt(t *testing.T) {
+	const pipeKey = "ABCDEFGHIJKLMNOPQRSTUVWXYZ123456"
+	rules := DifficultyNormal.Rules()
+	tileX := func(idx int) int {
+		return PipeStartOffsetX + idx*rules.PipeIntervalX
+	}
+
+	// Heights don't depend on the order in which the pipes are looked up.
+	forward := NewObject(InitialX16, InitialY16, 0, pipeKey, rules)
+	backward := NewObject(InitialX16, InitialY16, 0, pipeKey, rules)
+	const n = 1000
+	want := make([]int, n+1)
+	for idx := 1; idx <= n; idx++ {
+		y, ok := forward.PipeAt(tileX(idx))
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, y, 2)
+		assert.Less(t, y, 8)
+		want[idx] = y
+	}
+	for idx := n; idx >= 1; idx-- {
+		y, _ := backward.PipeAt(tileX(idx))
+		assert.Equal(t, want[idx], y)
+	}
+
+	// The course doesn't repeat after 256 pipes.
+	assert.NotEqual(t, want[1:257], want[257:513])
+
+	_, ok := forward.PipeAt(tileX(1) + 1)
+	assert.False(t, ok)
+	_, ok = forward.PipeAt(PipeStartOffsetX)
+	assert.False(t, ok)
+}
+
+func TestCheckpointFrame(t *testing.T) {
+	for _, difficulty := range []Difficulty{DifficultyEasy, DifficultyNormal, DifficultyHard} {
+		rules := difficulty.Rules()
+		obj := NewObject(InitialX16, InitialY16, 0, "pipeKey", rules)
+		// Score doesn't depend on the height, so the gopher can fly through the pipes.
+		for frame := 1; obj.Score() < 3*CheckpointInterval; frame++ {
+			score := obj.Score()
+			obj.Update(false)
+			if obj.Score() > score {
+				assert.Equal(t, frame, CheckpointFrame(obj.Score(), rules), "%s %d", difficulty, obj.Score())
+			}
+		}
+	}
+}
+
+func TestObject_PipeClearance(t *testing.T) {
+	rules := DifficultyNormal.Rules()
+	obj := NewObject(InitialX16, InitialY16, 0, "pipeKey", rules)
+	_, ok := obj.PipeClearance()
+	assert.False(t, ok)
+
+	// Put the gopher in the first pipe, right above the bottom of its gap.
+	tileX := PipeStartOffsetX + rules.PipeIntervalX
+	tileY, _ := obj.PipeAt(tileX)
+	obj.X16 = (tileX*TileSize - (60-rules.GopherWidth)/2) * Unit
+	obj.Y16 = ((tileY+rules.PipeGapY)*TileSize - 1 - rules.GopherHeight - (75-rules.GopherHeight)/2) * Unit
+	clearance, ok := obj.PipeClearance()
+	assert.True(t, ok)
+	assert.Equal(t, 0, clearance)
+	assert.False(t, obj.Hit())
+
+	obj.Y16 += Unit
+	clearance, _ = obj.PipeClearance()
+	assert.Equal(t, -1, clearance)
+	assert.True(t, obj.Hit())
+}
+
+func TestObject_AddPipes(t *testing.T) {
+	const pipeKey = "ABCDEFGHIJKLMNOPQRSTUVWXYZ123456"
+	rules := DifficultyNormal.Rules()
+	jumpHistory := []int{736, 1440, 2816, 4928, 6464, 8032, 10432, 11552, 13088, 14880, 15904, 17952, 19392, 20864, 21792, 23200, 24608, 26624, 27968, 29824, 31616, 32896, 34912, 36480, 37664, 39072, 40192, 41824, 43936}
+
+	keyed := NewReplayer(NewObject(InitialX16, InitialY16, 0, pipeKey, rules), jumpHistory)
+	revealed := NewReplayer(NewRevealedObject(InitialX16, InitialY16, 0, rules), jumpHistory)
+	assert.True(t, revealed.Obj.AddPipes(CoursePipes(pipeKey, 0, 3)))
+	_, ok := revealed.Obj.PipeAt(PipeStartOffsetX + 4*rules.PipeIntervalX)
+	assert.False(t, ok)
+
+	// Segments that overlap the known pipes are added from the first unknown one.
+	for !keyed.Obj.Hit() {
+		score := revealed.Obj.Score()
+		assert.True(t, revealed.Obj.AddPipes(CoursePipes(pipeKey, score+1, score+3)))
+		keyed.Update()
+		revealed.Update()
+		assert.Equal(t, keyed.Obj.Y16, revealed.Obj.Y16)
+		assert.Equal(t, keyed.Obj.Hit(), revealed.Obj.Hit())
+	}
+	assert.Equal(t, 9, revealed.Obj.Score())
+
+	// A segment after a gap can't be added.
+	assert.False(t, revealed.Obj.AddPipes(CoursePipes(pipeKey, 20, 21)))
+}
+
+func TestObject_HitsUnknownPipe(t *testing.T) {
+	const pipeKey = "ABCDEFGHIJKLMNOPQRSTUVWXYZ123456"
+	rules := DifficultyNormal.Rules()
+	jumpHistory := []int{736, 1440, 2816, 4928, 6464, 8032, 10432, 11552, 13088, 14880, 15904, 17952, 19392, 20864, 21792, 23200, 24608, 26624, 27968, 29824, 31616, 32896, 34912, 36480, 37664, 39072, 40192, 41824, 43936}
+
+	// Flying a course only revealed up to the third pipe, the gopher crashes into the next one,
+	// whose gap could be anywhere, where it flies on with the pipe key.
+	keyed := NewReplayer(NewObject(InitialX16, InitialY16, 0, pipeKey, rules), jumpHistory)
+	revealed := NewReplayer(NewRevealedObject(InitialX16, InitialY16, 0, rules), jumpHistory)
+	assert.True(t, revealed.Obj.AddPipes(CoursePipes(pipeKey, 0, 2)))
+	for !revealed.Obj.Hit() {
+		assert.False(t, revealed.Obj.HitsUnknownPipe())
+		keyed.Update()
+		revealed.Update()
+	}
+	assert.True(t, revealed.Obj.HitsUnknownPipe())
+	assert.False(t, keyed.Obj.Hit())
+}
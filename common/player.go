@@ -0,0 +1,46 @@
+package common
+
+import (
+	"strconv"
+	"time"
+)
+
+// MaxDisplayNameLength is the maximum number of runes in a display name.
+const MaxDisplayNameLength = 10
+
+// Player is an anonymous account, known to the server by a key that only its device holds.
+type Player struct {
+	ID          int
+	DisplayName string
+	CreatedAt   time.Time
+}
+
+func NewPlayer(id int, displayName string, createdAt time.Time) *Player {
+	return &Player{
+		ID:          id,
+		DisplayName: displayName,
+		CreatedAt:   createdAt,
+	}
+}
+
+// IDPlayerKey is the player key of a registered player.
+func IDPlayerKey(id int) string {
+	return "player:" + strconv.Itoa(id)
+}
+
+// PlayerStats sums up the plays of a player on a board.
+type PlayerStats struct {
+	Plays        int
+	AverageScore float64
+}
+
+// PlayerProfile is the record of a player on a board.
+type PlayerProfile struct {
+	Player *Player
+	Board  Board
+	Stats  *PlayerStats
+	// Bests holds the best score of each period, or nil if the player has not played in it.
+	Bests map[string]*Score
+	// RecentScores are the latest scores, newest first.
+	RecentScores []*Score
+}
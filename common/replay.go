@@ -0,0 +1,27 @@
+package common
+
+// Replayer plays a jump history back on an Object frame by frame.
+type Replayer struct {
+	Obj *Object
+
+	jumpHistory []int
+	next        int
+}
+
+func NewReplayer(obj *Object, jumpHistory []int) *Replayer {
+	return &Replayer{
+		Obj:         obj,
+		jumpHistory: jumpHistory,
+	}
+}
+
+// Update advances the gopher by one frame and reports whether it jumped.
+// A jump is taken when the gopher reaches the X16 of the next entry in the history.
+func (r *Replayer) Update() bool {
+	jump := r.next < len(r.jumpHistory) && r.jumpHistory[r.next] == r.Obj.X16+r.Obj.Rules.DeltaX16
+	if jump {
+		r.next++
+	}
+	r.Obj.Update(jump)
+	return jump
+}
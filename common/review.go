@@ -0,0 +1,26 @@
+package common
+
+// Review is the state of the moderation of a score.
+type Review string
+
+const (
+	// ReviewNone is a score that was not flagged.
+	ReviewNone Review = "NONE"
+	// ReviewPending is a flagged score waiting for a review, which is hidden from the boards.
+	ReviewPending  Review = "PENDING"
+	ReviewApproved Review = "APPROVED"
+	ReviewRejected Review = "REJECTED"
+)
+
+func (r Review) IsValid() bool {
+	switch r {
+	case ReviewNone, ReviewPending, ReviewApproved, ReviewRejected:
+		return true
+	}
+	return false
+}
+
+// IsListed reports whether scores in the review are shown on the boards.
+func (r Review) IsListed() bool {
+	return r == ReviewNone || r == ReviewApproved
+}
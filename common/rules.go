@@ -0,0 +1,69 @@
+package common
+
+// RulesVersion identifies a rule set. The rules of a released version must never change,
+// or the jump histories played with it re-simulate to different scores.
+// Any change to the physics, the course or the hitbox needs a new version.
+type RulesVersion int
+
+const (
+	RulesVersion1 RulesVersion = 1
+
+	// RulesVersionCurrent is the version new sessions of this build are played with.
+	RulesVersionCurrent = RulesVersion1
+)
+
+// Rules are the physics, course and hitbox parameters of a difficulty.
+type Rules struct {
+	PipeGapY      int
+	PipeIntervalX int
+	DeltaX16      int
+	DeltaVy16     int
+	VyLimit       int
+
+	// The size of the gopher's hitbox centred in its image
+	GopherWidth  int
+	GopherHeight int
+}
+
+var ruleSets = map[RulesVersion]map[Difficulty]Rules{
+	RulesVersion1: {
+		DifficultyEasy: {
+			PipeGapY:      6,
+			PipeIntervalX: 10,
+			DeltaX16:      24,
+			DeltaVy16:     4,
+			VyLimit:       80,
+			GopherWidth:   30,
+			GopherHeight:  60,
+		},
+		DifficultyNormal: {
+			PipeGapY:      5,
+			PipeIntervalX: 8,
+			DeltaX16:      32,
+			DeltaVy16:     4,
+			VyLimit:       96,
+			GopherWidth:   30,
+			GopherHeight:  60,
+		},
+		DifficultyHard: {
+			PipeGapY:      4,
+			PipeIntervalX: 7,
+			DeltaX16:      40,
+			DeltaVy16:     4,
+			VyLimit:       112,
+			GopherWidth:   30,
+			GopherHeight:  60,
+		},
+	},
+}
+
+func (v RulesVersion) IsSupported() bool {
+	_, ok := ruleSets[v]
+	return ok
+}
+
+// LookupRules returns the rules of the difficulty in the version.
+func LookupRules(version RulesVersion, difficulty Difficulty) (Rules, bool) {
+	rules, ok := ruleSets[version][difficulty]
+	return rules, ok
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLookupRules_Version1 pins the released rules: changing them would re-score stored jump histories.
+func TestLookupRules_Version1(t *testing.T) {
+	want := map[Difficulty]Rules{
+		DifficultyEasy:   {PipeGapY: 6, PipeIntervalX: 10, DeltaX16: 24, DeltaVy16: 4, VyLimit: 80, GopherWidth: 30, GopherHeight: 60},
+		DifficultyNormal: {PipeGapY: 5, PipeIntervalX: 8, DeltaX16: 32, DeltaVy16: 4, VyLimit: 96, GopherWidth: 30, GopherHeight: 60},
+		DifficultyHard:   {PipeGapY: 4, PipeIntervalX: 7, DeltaX16: 40, DeltaVy16: 4, VyLimit: 112, GopherWidth: 30, GopherHeight: 60},
+	}
+	for difficulty, rules := range want {
+		got, ok := LookupRules(RulesVersion1, difficulty)
+		assert.True(t, ok, difficulty)
+		assert.Equal(t, rules, got, difficulty)
+	}
+
+	_, ok := LookupRules(RulesVersion1, "UNKNOWN")
+	assert.False(t, ok)
+	_, ok = LookupRules(0, DifficultyNormal)
+	assert.False(t, ok)
+	assert.True(t, RulesVersionCurrent.IsSupported())
+	assert.False(t, RulesVersion(0).IsSupported())
+}
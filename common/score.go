@@ -3,17 +3,65 @@ package common
 import "time"
 
 type Score struct {
+	ID          int
 	Rank        int
 	DisplayName string
 	Score       int
 	CreatedAt   time.Time
+
+	// PlayerKey identifies the player on best-per-player leaderboards.
+	PlayerKey string
+	// PlayerID is the registered player of the score, or 0 if it was submitted by name only.
+	PlayerID int
+
+	// The session and the play that produced the score
+	Token        string
+	PipeKey      string
+	Course       Course
+	Difficulty   Difficulty
+	RulesVersion RulesVersion
+	JumpHistory  []int
+	// Pipes are the pipes a replay flies past, for clients that are not given its pipe key.
+	Pipes *PipeSegment
+
+	// Suspicion is how likely the play is to be generated by a program, from 0 to 1.
+	Suspicion float64
+	Review    Review
 }
 
-func NewScore(rank int, displayName string, score int, createdAt time.Time) *Score {
+func NewScore(id, rank int, displayName string, score int, createdAt time.Time) *Score {
 	return &Score{
+		ID:          id,
 		Rank:        rank,
 		DisplayName: displayName,
 		Score:       score,
 		CreatedAt:   createdAt,
 	}
 }
+
+func NewSubmittedScore(displayName string, score int, token, pipeKey string, course Course, difficulty Difficulty, rulesVersion RulesVersion, jumpHistory []int) *Score {
+	return &Score{
+		DisplayName:  displayName,
+		Score:        score,
+		PlayerKey:    NamePlayerKey(displayName),
+		Token:        token,
+		PipeKey:      pipeKey,
+		Course:       course,
+		Difficulty:   difficulty,
+		RulesVersion: rulesVersion,
+		JumpHistory:  jumpHistory,
+		Review:       ReviewNone,
+	}
+}
+
+// SetPlayer ties the score to the registered player, whose name it is listed under.
+func (s *Score) SetPlayer(player *Player) {
+	s.PlayerID = player.ID
+	s.PlayerKey = IDPlayerKey(player.ID)
+	s.DisplayName = player.DisplayName
+}
+
+// NamePlayerKey is the player key of a player known only by the display name.
+func NamePlayerKey(displayName string) string {
+	return "name:" + displayName
+}
@@ -2,18 +2,59 @@ package common
 
 import "time"
 
+// SessionStatus is the state of a session: created -> finished -> scored.
+type SessionStatus string
+
+const (
+	SessionCreated  SessionStatus = "created"
+	SessionFinished SessionStatus = "finished"
+	SessionScored   SessionStatus = "scored"
+)
+
 type Session struct {
 	Token      string
 	PipeKey    string
-	FinishedAt time.Time
-	CreatedAt  time.Time
+	Course     Course
+	Difficulty Difficulty
+	// RulesVersion is the version of the rules the client plays the session with.
+	RulesVersion RulesVersion
+	Status       SessionStatus
+	FinishedAt   time.Time
+	CreatedAt    time.Time
 }
 
-func NewSession(token, pipeKey string, finishedAt, createdAt time.Time) *Session {
+func NewSession(token, pipeKey string, course Course, difficulty Difficulty, rulesVersion RulesVersion, status SessionStatus, finishedAt, createdAt time.Time) *Session {
 	return &Session{
-		Token:      token,
-		PipeKey:    pipeKey,
-		FinishedAt: finishedAt,
-		CreatedAt:  createdAt,
+		Token:        token,
+		PipeKey:      pipeKey,
+		Course:       course,
+		Difficulty:   difficulty,
+		RulesVersion: rulesVersion,
+		Status:       status,
+		FinishedAt:   finishedAt,
+		CreatedAt:    createdAt,
+	}
+}
+
+// CheckpointInterval is the number of pipes between the checkpoints a client reports during a session.
+const CheckpointInterval = 5
+
+// Checkpoint records when the server learned that a session had passed Score pipes.
+type Checkpoint struct {
+	Score     int
+	ArrivedAt time.Time
+}
+
+func NewCheckpoint(score int, arrivedAt time.Time) *Checkpoint {
+	return &Checkpoint{
+		Score:     score,
+		ArrivedAt: arrivedAt,
 	}
 }
+
+// CheckpointFrame returns the frame at which a replay on the rules passes score pipes.
+// The gopher moves DeltaX16 every frame, so it only depends on the distance to the pipe.
+func CheckpointFrame(score int, rules Rules) int {
+	x16 := (PipeStartOffsetX + score*rules.PipeIntervalX) * TileSize * Unit
+	return (x16 + rules.DeltaX16 - 1) / rules.DeltaX16
+}
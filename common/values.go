@@ -10,15 +10,9 @@ const (
 	SmallFontSize    = FontSize / 2
 	PipeWidth        = TileSize * 2
 	PipeStartOffsetX = 8
-	PipeIntervalX    = 8
-	PipeGapY         = 5
 	InitialX16       = 0
 	InitialY16       = 100 * 16
 	InitialCameraX   = -240
 	InitialCameraY   = 0
 	Unit             = 16
-	VyLimit          = 96
-	DeltaX16         = 32
-	DeltaVy16        = 4
-	DeltaCameraX     = 2
 )
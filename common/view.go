@@ -0,0 +1,15 @@
+package common
+
+// View selects the scores listed on a leaderboard.
+type View string
+
+const (
+	// ViewBest lists the best score of each player, which is the default of the public leaderboard.
+	ViewBest View = "BEST"
+	// ViewAll lists every score, so a player may appear many times.
+	ViewAll View = "ALL"
+)
+
+func (v View) IsValid() bool {
+	return v == ViewBest || v == ViewAll
+}
@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionNotFinished   = errors.New("session not finished")
+	ErrSessionAlreadyScored = errors.New("session already scored")
+	ErrScoreNotFound        = errors.New("score not found")
+	ErrScoreNotRanked       = errors.New("score not ranked in the period")
+	ErrReplayNotFound       = errors.New("replay not found")
+	ErrInvalidCourse        = errors.New("invalid course")
+	ErrInvalidDifficulty    = errors.New("invalid difficulty")
+	ErrInvalidView          = errors.New("invalid view")
+	ErrUnsupportedRules     = errors.New("unsupported rules version")
+	ErrPlayerNotFound       = errors.New("player not found")
+	ErrInvalidPlayerKey     = errors.New("invalid player key")
+	ErrInvalidDisplayName   = errors.New("invalid display name")
+	ErrInvalidJumpHistory   = errors.New("invalid jump history")
+	ErrTooManyJumps         = errors.New("too many jumps")
+	ErrTooManyFrames        = errors.New("too many frames")
+	ErrSessionFinished      = errors.New("session already finished")
+	ErrInvalidCheckpoint    = errors.New("invalid checkpoint")
+	ErrCheckpointMismatch   = errors.New("replay does not match the checkpoints")
+	ErrScoreNotFlagged      = errors.New("score not flagged for review")
+	ErrCopiedReplay         = errors.New("jump history copies an existing score")
+	ErrChallengeDisabled    = errors.New("daily challenge disabled")
+)
+
+// DisplayNameViolation is the rule of the name policy that a display name breaks.
+type DisplayNameViolation string
+
+const (
+	DisplayNameEmpty   DisplayNameViolation = "EMPTY"
+	DisplayNameTooLong DisplayNameViolation = "TOO_LONG"
+	DisplayNameBlocked DisplayNameViolation = "BLOCKED"
+)
+
+// DisplayNameError is returned for a display name rejected by the name policy. It wraps ErrInvalidDisplayName.
+type DisplayNameError struct {
+	Reason DisplayNameViolation
+}
+
+func (e *DisplayNameError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidDisplayName, e.Reason)
+}
+
+func (e *DisplayNameError) Unwrap() error {
+	return ErrInvalidDisplayName
+}
+
+// JumpHistoryViolation is the way a jump history fails to be a replay of a play.
+type JumpHistoryViolation string
+
+const (
+	JumpHistoryOutOfOrder JumpHistoryViolation = "OUT_OF_ORDER"
+	JumpHistoryOffGrid    JumpHistoryViolation = "OFF_GRID"
+	JumpHistoryDuplicate  JumpHistoryViolation = "DUPLICATE"
+	JumpHistoryAfterCrash JumpHistoryViolation = "AFTER_CRASH"
+)
+
+// JumpHistoryError is returned for a jump history whose entry at Index breaks the rule of Reason.
+// It wraps ErrInvalidJumpHistory.
+type JumpHistoryError struct {
+	Reason JumpHistoryViolation
+	Index  int
+}
+
+func (e *JumpHistoryError) Error() string {
+	return fmt.Sprintf("%v: %s at %d", ErrInvalidJumpHistory, e.Reason, e.Index)
+}
+
+func (e *JumpHistoryError) Unwrap() error {
+	return ErrInvalidJumpHistory
+}
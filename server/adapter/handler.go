@@ -1,36 +1,96 @@
 package adapter
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ponyo877/flappy-ranking/common"
 )
 
+// DefaultMaxBodyBytes is the request body size limit if it is not configured,
+// which fits a jump history of usecase.DefaultMaxJumps entries.
+const DefaultMaxBodyBytes = 256 << 10
+
+type Config struct {
+	// MaxBodyBytes limits the size of request bodies. Larger ones are answered with 413.
+	MaxBodyBytes int64
+	// AdminSecret is the bearer token of the review endpoints, which are disabled if it is empty.
+	AdminSecret string
+}
+
 type Adapter struct {
 	usecase Usecase
+	config  Config
 }
 
-func NewAdapter(usecase Usecase) *Adapter {
-	return &Adapter{usecase: usecase}
+func NewAdapter(usecase Usecase, config Config) *Adapter {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &Adapter{usecase: usecase, config: config}
 }
 
 func (s *Adapter) GenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
-	token := common.NewUlID()
-	pipeKey := common.NewUlID()
-	if err := s.usecase.RegisterSession(token, pipeKey); err != nil {
+	// The body is optional: course and difficulty select the board, rulesVersion is the version
+	// of the rules the client plays with, and ghostId binds the session to the course of a replay to race against.
+	var req struct {
+		Course       common.Course       `json:"course"`
+		Difficulty   common.Difficulty   `json:"difficulty"`
+		RulesVersion common.RulesVersion `json:"rulesVersion"`
+		GhostID      int                 `json:"ghostId"`
+	}
+	if err := s.decodeBody(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("Failed to decode request body: %v", err)
+		writeDecodeError(w, err)
+		return
+	}
+	if req.RulesVersion == 0 {
+		// Clients released before rules versions play version 1.
+		req.RulesVersion = common.RulesVersion1
+	}
+	session, ghost, err := s.usecase.RegisterSession(newBoard(req.Course, req.Difficulty), req.RulesVersion, req.GhostID)
+	if err != nil {
 		log.Printf("Failed to register session: %v", err)
-		http.Error(w, "Failed to register session", http.StatusInternalServerError)
+		message, invalid := invalidBoard(err)
+		switch {
+		case invalid:
+			http.Error(w, message, http.StatusBadRequest)
+		case errors.Is(err, ErrUnsupportedRules):
+			http.Error(w, "Unsupported rules version, please update the game", http.StatusBadRequest)
+		case errors.Is(err, ErrScoreNotFound), errors.Is(err, ErrReplayNotFound):
+			http.Error(w, "Replay not found", http.StatusNotFound)
+		case errors.Is(err, ErrChallengeDisabled):
+			http.Error(w, "Daily challenge not available", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to register session", http.StatusInternalServerError)
+		}
 		return
 	}
+	// The pipe key is never sent: the course is revealed in segments by checkpoints as it is played.
 	responseBody := struct {
-		Token   string `json:"token"`
-		PipeKey string `json:"pipeKey"`
+		Token      string            `json:"token"`
+		Pipes      *PipesJSON        `json:"pipes"`
+		Course     common.Course     `json:"course"`
+		Difficulty common.Difficulty `json:"difficulty"`
+		Ghost      *ReplayJSON       `json:"ghost,omitempty"`
 	}{
-		Token:   token,
-		PipeKey: pipeKey,
+		Token:      session.Token,
+		Pipes:      NewPipesJSON(s.usecase.RevealPipes(session, ghost)),
+		Course:     session.Course,
+		Difficulty: session.Difficulty,
+	}
+	if ghost != nil {
+		replay := NewReplayJSON(ghost)
+		responseBody.Ghost = &replay
 	}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
 		log.Printf("Failed to encode response body: %v", err)
@@ -40,17 +100,88 @@ func (s *Adapter) GenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Adapter) ListScoreHandler(w http.ResponseWriter, r *http.Request) {
-	period := r.URL.Query().Get("period")
-	scores, err := s.usecase.ListScore(period)
+	query := r.URL.Query()
+	period := query.Get("period")
+	board := newBoard(common.Course(query.Get("course")), common.Difficulty(query.Get("difficulty")))
+	var limit int
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Printf("Invalid limit: %q", v)
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var after *common.ScoreCursor
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			log.Printf("Invalid cursor: %v", err)
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = c
+	}
+	scores, next, err := s.usecase.ListScore(board, newView(common.View(query.Get("view"))), period, after, limit)
 	if err != nil {
 		log.Printf("Failed to get score: %v", err)
+		if message, ok := invalidBoard(err); ok {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrInvalidView) {
+			http.Error(w, "Invalid view", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to get score", http.StatusInternalServerError)
 		return
 	}
 
 	responseBody := struct {
+		Scores     []ScoreJSON `json:"scores"`
+		NextCursor string      `json:"nextCursor,omitempty"`
+	}{Scores: NewScoreJSONList(scores)}
+	if next != nil {
+		responseBody.NextCursor = encodeCursor(next)
+	}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Adapter) ListScoreAroundHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid score id: %v", err)
+		http.Error(w, "Invalid score id", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	scores, entry, err := s.usecase.ListScoreAround(id, newView(common.View(query.Get("view"))), query.Get("period"))
+	if err != nil {
+		log.Printf("Failed to get scores around: %v", err)
+		switch {
+		case errors.Is(err, ErrInvalidView):
+			http.Error(w, "Invalid view", http.StatusBadRequest)
+		case errors.Is(err, ErrScoreNotFound):
+			http.Error(w, "Score not found", http.StatusNotFound)
+		case errors.Is(err, ErrScoreNotRanked):
+			http.Error(w, "Score not ranked in the period", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to get scores", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// In the best view the entry may be an earlier best score of the player, so its id is returned with its rank.
+	responseBody := struct {
+		Rank   int         `json:"rank"`
+		ID     int         `json:"id"`
 		Scores []ScoreJSON `json:"scores"`
-	}{NewScoreJSONList(scores)}
+	}{Rank: entry.Rank, ID: entry.ID, Scores: NewScoreJSONList(scores)}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
 		log.Printf("Failed to encode response body: %v", err)
 		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
@@ -58,6 +189,72 @@ func (s *Adapter) ListScoreHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Adapter) RegisterPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	// key is generated and kept by the client; sending it again signs in as the same player.
+	var req struct {
+		Key         string `json:"key"`
+		DisplayName string `json:"displayName"`
+	}
+	if err := s.decodeBody(w, r, &req); err != nil {
+		log.Printf("Failed to decode request body: %v", err)
+		writeDecodeError(w, err)
+		return
+	}
+	player, err := s.usecase.RegisterPlayer(req.Key, req.DisplayName)
+	if err != nil {
+		log.Printf("Failed to register player: %v", err)
+		if nameErr := (*DisplayNameError)(nil); errors.As(err, &nameErr) {
+			writeDisplayNameError(w, nameErr)
+			return
+		}
+		if errors.Is(err, ErrInvalidPlayerKey) {
+			http.Error(w, "Invalid player key", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to register player", http.StatusInternalServerError)
+		return
+	}
+	responseBody := struct {
+		ID          int    `json:"id"`
+		DisplayName string `json:"displayName"`
+	}{player.ID, player.DisplayName}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Adapter) GetPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid player id: %v", err)
+		http.Error(w, "Invalid player id", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	board := newBoard(common.Course(query.Get("course")), common.Difficulty(query.Get("difficulty")))
+	profile, err := s.usecase.GetPlayerProfile(id, board)
+	if err != nil {
+		log.Printf("Failed to get player: %v", err)
+		if message, ok := invalidBoard(err); ok {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrPlayerNotFound) {
+			http.Error(w, "Player not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get player", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(NewPlayerProfileJSON(profile)); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
 func (s *Adapter) RegisterScoreHandler(w http.ResponseWriter, r *http.Request) {
 	token := r.PathValue("token")
 	if token == "" {
@@ -65,29 +262,117 @@ func (s *Adapter) RegisterScoreHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Token not provided", http.StatusBadRequest)
 		return
 	}
+	// playerKey is optional, scores without it are listed under displayName only.
 	var req struct {
 		DisplayName string `json:"displayName"`
+		PlayerKey   string `json:"playerKey"`
 		JumpHistory []int  `json:"jumpHistory"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := s.decodeBody(w, r, &req); err != nil {
 		log.Printf("Failed to decode request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, err)
 		return
 	}
 	score, err := s.usecase.CalcScore(req.JumpHistory, token)
 	if err != nil {
 		log.Printf("Failed to calculate score: %v", err)
+		if message, ok := sessionConflict(err); ok {
+			http.Error(w, message, http.StatusConflict)
+			return
+		}
+		if historyErr := (*JumpHistoryError)(nil); errors.As(err, &historyErr) {
+			writeJumpHistoryError(w, historyErr)
+			return
+		}
+		switch {
+		case errors.Is(err, ErrTooManyJumps):
+			http.Error(w, "Too many jumps", http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, ErrTooManyFrames):
+			http.Error(w, "Play too long to simulate", http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, ErrCheckpointMismatch):
+			http.Error(w, "Replay does not match the checkpoints", http.StatusUnprocessableEntity)
+			return
+		}
 		http.Error(w, "Failed to calculate score", http.StatusBadRequest)
 		return
 	}
-	if err := s.usecase.RegisterScore(req.DisplayName, score); err != nil {
+	id, err := s.usecase.RegisterScore(token, req.DisplayName, req.PlayerKey, score, req.JumpHistory)
+	if err != nil {
 		log.Printf("Failed to register score: %v", err)
+		if message, ok := sessionConflict(err); ok {
+			http.Error(w, message, http.StatusConflict)
+			return
+		}
+		if nameErr := (*DisplayNameError)(nil); errors.As(err, &nameErr) {
+			writeDisplayNameError(w, nameErr)
+			return
+		}
+		switch {
+		case errors.Is(err, ErrInvalidPlayerKey):
+			http.Error(w, "Invalid player key", http.StatusBadRequest)
+			return
+		case errors.Is(err, ErrPlayerNotFound):
+			http.Error(w, "Player not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrCopiedReplay):
+			writeCopiedReplayError(w)
+			return
+		}
 		http.Error(w, "Failed to register score", http.StatusInternalServerError)
 		return
 	}
 	responseBody := struct {
+		ID    int `json:"id"`
 		Score int `json:"score"`
-	}{score}
+	}{id, score}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RecordCheckpointHandler records that the session has passed the score in the body, reported every
+// common.CheckpointInterval pipes while it is played, and responds with the pipes it reveals.
+func (s *Adapter) RecordCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if token == "" {
+		log.Printf("Token not provided in path")
+		http.Error(w, "Token not provided", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Score int `json:"score"`
+	}
+	if err := s.decodeBody(w, r, &req); err != nil {
+		log.Printf("Failed to decode request body: %v", err)
+		writeDecodeError(w, err)
+		return
+	}
+	pipes, err := s.usecase.RecordCheckpoint(token, req.Score)
+	if err != nil {
+		log.Printf("Failed to record checkpoint: %v", err)
+		switch {
+		case errors.Is(err, ErrSessionNotFound):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		case errors.Is(err, ErrSessionFinished):
+			http.Error(w, "Session already finished", http.StatusConflict)
+		case errors.Is(err, ErrInvalidCheckpoint):
+			http.Error(w, "Invalid checkpoint", http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to record checkpoint", http.StatusInternalServerError)
+		}
+		return
+	}
+	responseBody := struct {
+		Status string     `json:"status"`
+		Pipes  *PipesJSON `json:"pipes"`
+	}{
+		Status: "ok",
+		Pipes:  NewPipesJSON(pipes),
+	}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
 		log.Printf("Failed to encode response body: %v", err)
 		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
@@ -102,15 +387,30 @@ func (s *Adapter) FinishSessionHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Token not provided", http.StatusBadRequest)
 		return
 	}
-	if err := s.usecase.FinishSession(token); err != nil {
+	token, err := s.usecase.FinishSession(token)
+	if err != nil {
 		log.Printf("Failed to finish session: %v", err)
+		if errors.Is(err, ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrSessionFinished) {
+			http.Error(w, "Session already finished", http.StatusConflict)
+			return
+		}
+		if message, ok := sessionConflict(err); ok {
+			http.Error(w, message, http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to finish session", http.StatusInternalServerError)
 		return
 	}
 	responseBody := struct {
 		Status string `json:"status"`
+		Token  string `json:"token"`
 	}{
 		Status: "ok",
+		Token:  token,
 	}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
 		log.Printf("Failed to encode response body: %v", err)
@@ -119,7 +419,261 @@ func (s *Adapter) FinishSessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Adapter) GetReplayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid replay id: %v", err)
+		http.Error(w, "Invalid replay id", http.StatusBadRequest)
+		return
+	}
+	replay, err := s.usecase.GetReplay(id)
+	if err != nil {
+		log.Printf("Failed to get replay: %v", err)
+		if errors.Is(err, ErrScoreNotFound) || errors.Is(err, ErrReplayNotFound) {
+			http.Error(w, "Replay not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get replay", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(NewReplayJSON(replay)); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Adapter) GetReviewReplayHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid score id: %v", err)
+		http.Error(w, "Invalid score id", http.StatusBadRequest)
+		return
+	}
+	replay, err := s.usecase.GetReviewReplay(id)
+	if err != nil {
+		log.Printf("Failed to get review replay: %v", err)
+		switch {
+		case errors.Is(err, ErrScoreNotFound), errors.Is(err, ErrReplayNotFound):
+			http.Error(w, "Replay not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrScoreNotFlagged):
+			http.Error(w, "Score not flagged for review", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to get replay", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(NewReplayJSON(replay)); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Adapter) ListPendingScoresHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Printf("Invalid limit: %q", v)
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	scores, err := s.usecase.ListPendingScores(limit)
+	if err != nil {
+		log.Printf("Failed to list pending scores: %v", err)
+		http.Error(w, "Failed to list pending scores", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(NewPendingScoreJSONList(scores)); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Adapter) ReviewScoreHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid score id: %v", err)
+		http.Error(w, "Invalid score id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Approved bool `json:"approved"`
+	}
+	if err := s.decodeBody(w, r, &req); err != nil {
+		log.Printf("Failed to decode request body: %v", err)
+		writeDecodeError(w, err)
+		return
+	}
+	if err := s.usecase.ReviewScore(id, req.Approved); err != nil {
+		log.Printf("Failed to review score: %v", err)
+		switch {
+		case errors.Is(err, ErrScoreNotFound):
+			http.Error(w, "Score not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrScoreNotFlagged):
+			http.Error(w, "Score not flagged for review", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to review score", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+		http.Error(w, "Failed to encode response body", http.StatusInternalServerError)
+		return
+	}
+}
+
+// authorizeAdmin checks the bearer token of a review request and answers it if it is not authorized.
+// The review endpoints are not found if no AdminSecret is configured.
+func (s *Adapter) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.config.AdminSecret == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminSecret)) != 1 {
+		log.Printf("Unauthorized review request from %s", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// encodeCursor encodes the cursor of a leaderboard page into an opaque string.
+func encodeCursor(c *common.ScoreCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.Score, c.ID)))
+}
+
+func decodeCursor(s string) (*common.ScoreCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c common.ScoreCursor
+	if _, err := fmt.Sscanf(string(b), "%d:%d", &c.Score, &c.ID); err != nil {
+		return nil, fmt.Errorf("malformed cursor %q: %w", b, err)
+	}
+	return &c, nil
+}
+
+// newBoard fills in the random course and the normal difficulty for parameters that are not given.
+func newBoard(course common.Course, difficulty common.Difficulty) common.Board {
+	if course == "" {
+		course = common.CourseRandom
+	}
+	if difficulty == "" {
+		difficulty = common.DifficultyNormal
+	}
+	return common.NewBoard(course, difficulty)
+}
+
+// writeDisplayNameError writes the 400 response of a display name rejected by the name policy.
+// Its JSON body tells clients which rule the name breaks:
+//
+//	{"error":"INVALID_DISPLAY_NAME","reason":"TOO_LONG","maxLength":10}
+func writeDisplayNameError(w http.ResponseWriter, err *DisplayNameError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	responseBody := struct {
+		Error     string               `json:"error"`
+		Reason    DisplayNameViolation `json:"reason"`
+		MaxLength int                  `json:"maxLength"`
+	}{"INVALID_DISPLAY_NAME", err.Reason, common.MaxDisplayNameLength}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+	}
+}
+
+// decodeBody decodes the JSON body of the request, reading at most MaxBodyBytes of it.
+func (s *Adapter) decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes)).Decode(v)
+}
+
+func writeDecodeError(w http.ResponseWriter, err error) {
+	if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+}
+
+func writeJumpHistoryError(w http.ResponseWriter, err *JumpHistoryError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	responseBody := struct {
+		Error  string               `json:"error"`
+		Reason JumpHistoryViolation `json:"reason"`
+		Index  int                  `json:"index"`
+	}{"INVALID_JUMP_HISTORY", err.Reason, err.Index}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+	}
+}
+
+// writeCopiedReplayError writes the 409 response of a jump history that copies an existing score.
+// Its JSON body tells clients apart from the conflicts of sessions that were already scored:
+//
+//	{"error":"COPIED_REPLAY"}
+func writeCopiedReplayError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	responseBody := struct {
+		Error string `json:"error"`
+	}{"COPIED_REPLAY"}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		log.Printf("Failed to encode response body: %v", err)
+	}
+}
+
+// newView selects the best view when the view is not given.
+func newView(view common.View) common.View {
+	if view == "" {
+		return common.ViewBest
+	}
+	return view
+}
+
+// invalidBoard returns the message of a 400 response for errors of unknown boards.
+func invalidBoard(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrInvalidCourse):
+		return "Invalid course", true
+	case errors.Is(err, ErrInvalidDifficulty):
+		return "Invalid difficulty", true
+	}
+	return "", false
+}
+
+// sessionConflict returns the message of a 409 response for errors of session state transitions.
+func sessionConflict(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrSessionAlreadyScored):
+		return "Score already registered for this session", true
+	case errors.Is(err, ErrSessionNotFinished):
+		return "Session not finished", true
+	}
+	return "", false
+}
+
 type ScoreJSON struct {
+	ID          int       `json:"id"`
 	Rank        int       `json:"rank"`
 	DisplayName string    `json:"display_name"`
 	Score       int       `json:"score"`
@@ -128,6 +682,7 @@ type ScoreJSON struct {
 
 func NewScoreJSON(score *common.Score) ScoreJSON {
 	return ScoreJSON{
+		ID:          score.ID,
 		Rank:        score.Rank,
 		DisplayName: score.DisplayName,
 		Score:       score.Score,
@@ -142,3 +697,128 @@ func NewScoreJSONList(scores []*common.Score) []ScoreJSON {
 	}
 	return scoreJSONs
 }
+
+// PlayerProfileJSON is the profile of a player on a board.
+type PlayerProfileJSON struct {
+	ID           int                 `json:"id"`
+	DisplayName  string              `json:"displayName"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	Course       common.Course       `json:"course"`
+	Difficulty   common.Difficulty   `json:"difficulty"`
+	Plays        int                 `json:"plays"`
+	AverageScore float64             `json:"averageScore"`
+	Bests        map[string]*RunJSON `json:"bests"`
+	RecentRuns   []RunJSON           `json:"recentRuns"`
+}
+
+func NewPlayerProfileJSON(profile *common.PlayerProfile) PlayerProfileJSON {
+	bests := make(map[string]*RunJSON, len(profile.Bests))
+	for period, score := range profile.Bests {
+		if score == nil {
+			bests[period] = nil
+			continue
+		}
+		run := NewRunJSON(score)
+		bests[period] = &run
+	}
+	recentRuns := make([]RunJSON, len(profile.RecentScores))
+	for i, score := range profile.RecentScores {
+		recentRuns[i] = NewRunJSON(score)
+	}
+	return PlayerProfileJSON{
+		ID:           profile.Player.ID,
+		DisplayName:  profile.Player.DisplayName,
+		CreatedAt:    profile.Player.CreatedAt,
+		Course:       profile.Board.Course,
+		Difficulty:   profile.Board.Difficulty,
+		Plays:        profile.Stats.Plays,
+		AverageScore: profile.Stats.AverageScore,
+		Bests:        bests,
+		RecentRuns:   recentRuns,
+	}
+}
+
+// PendingScoreJSON is a flagged score waiting for a review, with the link to its replay.
+type PendingScoreJSON struct {
+	ID          int               `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Score       int               `json:"score"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Course      common.Course     `json:"course"`
+	Difficulty  common.Difficulty `json:"difficulty"`
+	Suspicion   float64           `json:"suspicion"`
+	Replay      string            `json:"replay"`
+}
+
+func NewPendingScoreJSONList(scores []*common.Score) []PendingScoreJSON {
+	pending := make([]PendingScoreJSON, len(scores))
+	for i, score := range scores {
+		pending[i] = PendingScoreJSON{
+			ID:          score.ID,
+			DisplayName: score.DisplayName,
+			Score:       score.Score,
+			CreatedAt:   score.CreatedAt,
+			Course:      score.Course,
+			Difficulty:  score.Difficulty,
+			Suspicion:   score.Suspicion,
+			Replay:      fmt.Sprintf("/api/admin/scores/%d/replay", score.ID),
+		}
+	}
+	return pending
+}
+
+// RunJSON is a score of a player with the link to its replay.
+type RunJSON struct {
+	ID        int       `json:"id"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"createdAt"`
+	Replay    string    `json:"replay"`
+}
+
+func NewRunJSON(score *common.Score) RunJSON {
+	return RunJSON{
+		ID:        score.ID,
+		Score:     score.Score,
+		CreatedAt: score.CreatedAt,
+		Replay:    fmt.Sprintf("/api/replays/%d", score.ID),
+	}
+}
+
+type ReplayJSON struct {
+	ID           int                 `json:"id"`
+	DisplayName  string              `json:"displayName"`
+	Score        int                 `json:"score"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	PipeKey      string              `json:"pipeKey,omitempty"`
+	Pipes        *PipesJSON          `json:"pipes,omitempty"`
+	Difficulty   common.Difficulty   `json:"difficulty"`
+	RulesVersion common.RulesVersion `json:"rulesVersion"`
+	JumpHistory  []int               `json:"jumpHistory"`
+}
+
+func NewReplayJSON(score *common.Score) ReplayJSON {
+	return ReplayJSON{
+		ID:           score.ID,
+		DisplayName:  score.DisplayName,
+		Score:        score.Score,
+		CreatedAt:    score.CreatedAt,
+		PipeKey:      score.PipeKey,
+		Pipes:        NewPipesJSON(score.Pipes),
+		Difficulty:   score.Difficulty,
+		RulesVersion: score.RulesVersion,
+		JumpHistory:  score.JumpHistory,
+	}
+}
+
+// PipesJSON is a segment of a course: the heights of the pipes from the From-th on.
+type PipesJSON struct {
+	From   int   `json:"from"`
+	TileYs []int `json:"tileYs"`
+}
+
+func NewPipesJSON(segment *common.PipeSegment) *PipesJSON {
+	if segment == nil {
+		return nil
+	}
+	return &PipesJSON{From: segment.From, TileYs: segment.TileYs}
+}
@@ -0,0 +1,516 @@
+package adapter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/ponyo877/flappy-ranking/server/usecase"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *http.ServeMux {
+	a := adapter.NewAdapter(usecase.NewScoreUsecase(repository.NewMemoryRepository(), usecase.Config{ChallengeSecret: "secret"}), adapter.Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/tokens", a.GenerateTokenHandler)
+	mux.HandleFunc("GET /api/scores", a.ListScoreHandler)
+	mux.HandleFunc("GET /api/scores/{id}/around", a.ListScoreAroundHandler)
+	mux.HandleFunc("POST /api/players", a.RegisterPlayerHandler)
+	mux.HandleFunc("GET /api/players/{id}", a.GetPlayerHandler)
+	mux.HandleFunc("POST /api/scores/{token}", a.RegisterScoreHandler)
+	mux.HandleFunc("POST /api/sessions/{token}", a.FinishSessionHandler)
+	mux.HandleFunc("POST /api/sessions/{token}/checkpoints", a.RecordCheckpointHandler)
+	mux.HandleFunc("GET /api/replays/{id}", a.GetReplayHandler)
+	return mux
+}
+
+func serve(mux *http.ServeMux, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+// ceilingJumpHistory jumps on every frame until the gopher hits the ceiling, which ends the game within a second.
+func ceilingJumpHistory() []int {
+	var jumpHistory []int
+	deltaX16 := common.DifficultyNormal.Rules().DeltaX16
+	for x16 := deltaX16; x16 <= 40*deltaX16; x16 += deltaX16 {
+		jumpHistory = append(jumpHistory, x16)
+	}
+	return jumpHistory
+}
+
+func TestAdapter_PlayFlow(t *testing.T) {
+	mux := newTestServer()
+
+	rec := serve(mux, http.MethodPost, "/api/tokens", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var token struct {
+		Token   string             `json:"token"`
+		PipeKey string             `json:"pipeKey"`
+		Pipes   *adapter.PipesJSON `json:"pipes"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	assert.NotEmpty(t, token.Token)
+	assert.Empty(t, token.PipeKey)
+	assert.NotNil(t, token.Pipes)
+
+	rec = serve(mux, http.MethodPost, "/api/sessions/"+token.Token, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// Sessions stored in the database keep their token.
+	assert.JSONEq(t, `{"status":"ok","token":"`+token.Token+`"}`, rec.Body.String())
+
+	// A malformed jump history is rejected with the reason, and the token can still be used.
+	rec = serve(mux, http.MethodPost, "/api/scores/"+token.Token, `{"displayName":"gopher","jumpHistory":[128,64]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error":"INVALID_JUMP_HISTORY","reason":"OUT_OF_ORDER","index":1}`, rec.Body.String())
+
+	jumpHistory := ceilingJumpHistory()
+	body, _ := json.Marshal(map[string]any{"displayName": "gopher", "jumpHistory": jumpHistory})
+	rec = serve(mux, http.MethodPost, "/api/scores/"+token.Token, string(body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":1,"score":0}`, rec.Body.String())
+
+	// A token registers only one score.
+	rec = serve(mux, http.MethodPost, "/api/scores/"+token.Token, string(body))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = serve(mux, http.MethodPost, "/api/sessions/"+token.Token, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = serve(mux, http.MethodGet, "/api/scores?period=DAILY", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Scores []adapter.ScoreJSON `json:"scores"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	if assert.Len(t, list.Scores, 1) {
+		assert.Equal(t, "gopher", list.Scores[0].DisplayName)
+		assert.Equal(t, 1, list.Scores[0].Rank)
+
+		rec = serve(mux, http.MethodGet, fmt.Sprintf("/api/replays/%d", list.Scores[0].ID), "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		// Replays are camelCase throughout.
+		assert.Contains(t, rec.Body.String(), `"displayName":"gopher"`)
+		assert.Contains(t, rec.Body.String(), `"createdAt":`)
+		var replay adapter.ReplayJSON
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&replay))
+		assert.NotEmpty(t, replay.PipeKey)
+		assert.Equal(t, common.RulesVersion1, replay.RulesVersion)
+		assert.Equal(t, jumpHistory, replay.JumpHistory)
+	}
+
+	rec = serve(mux, http.MethodGet, "/api/scores/1/around?period=DAILY", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var around struct {
+		Rank   int                 `json:"rank"`
+		ID     int                 `json:"id"`
+		Scores []adapter.ScoreJSON `json:"scores"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&around))
+	assert.Equal(t, 1, around.Rank)
+	assert.Equal(t, 1, around.ID)
+	assert.Len(t, around.Scores, 1)
+
+	rec = serve(mux, http.MethodGet, "/api/scores/999/around", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(mux, http.MethodGet, "/api/scores/1/around?view=UNKNOWN", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(mux, http.MethodGet, "/api/replays/999", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Race against the ghost of the registered score
+	rec = serve(mux, http.MethodPost, "/api/tokens", fmt.Sprintf(`{"ghostId":%d}`, list.Scores[0].ID))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var ghostToken struct {
+		Token string              `json:"token"`
+		Pipes *adapter.PipesJSON  `json:"pipes"`
+		Ghost *adapter.ReplayJSON `json:"ghost"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&ghostToken))
+	assert.NotEqual(t, token.Token, ghostToken.Token)
+	assert.Equal(t, token.Pipes, ghostToken.Pipes)
+	if assert.NotNil(t, ghostToken.Ghost) {
+		assert.Equal(t, jumpHistory, ghostToken.Ghost.JumpHistory)
+	}
+
+	rec = serve(mux, http.MethodPost, "/api/tokens", `{"ghostId":999}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdapter_RegisterScoreHandler(t *testing.T) {
+	mux := newTestServer()
+	rec := serve(mux, http.MethodPost, "/api/tokens", "")
+	var unfinished struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&unfinished))
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{
+			name:   "unfinished session",
+			token:  unfinished.Token,
+			body:   `{"displayName":"gopher","jumpHistory":[]}`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "unknown token",
+			token:  "unknown",
+			body:   `{"displayName":"gopher","jumpHistory":[]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid body",
+			token:  "unknown",
+			body:   `{`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too many jumps",
+			token:  unfinished.Token,
+			body:   `{"displayName":"gopher","jumpHistory":[` + strings.Repeat("32,", usecase.DefaultMaxJumps) + `32]}`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "body too large",
+			token:  unfinished.Token,
+			body:   `{"displayName":"` + strings.Repeat("g", adapter.DefaultMaxBodyBytes) + `"}`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux, http.MethodPost, "/api/scores/"+tt.token, tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+// copyingUsecase scores every play, but rejects its registration as a copy of an existing score.
+type copyingUsecase struct {
+	adapter.Usecase
+}
+
+func (copyingUsecase) CalcScore(jumpHistory []int, token string) (int, error) {
+	return 10, nil
+}
+
+func (copyingUsecase) RegisterScore(token, name, playerKey string, score int, jumpHistory []int) (int, error) {
+	return 0, adapter.ErrCopiedReplay
+}
+
+func TestAdapter_RegisterScoreHandler_copiedReplay(t *testing.T) {
+	a := adapter.NewAdapter(copyingUsecase{}, adapter.Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/scores/{token}", a.RegisterScoreHandler)
+
+	// Copies are told apart from the conflicts of sessions by the error code.
+	rec := serve(mux, http.MethodPost, "/api/scores/token", `{"displayName":"gopher","jumpHistory":[]}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"error":"COPIED_REPLAY"}`, rec.Body.String())
+}
+
+func TestAdapter_RecordCheckpointHandler(t *testing.T) {
+	mux := newTestServer()
+	rec := serve(mux, http.MethodPost, "/api/tokens", "")
+	var token struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	checkpoint := func(token, body string) int {
+		return serve(mux, http.MethodPost, "/api/sessions/"+token+"/checkpoints", body).Code
+	}
+
+	// The first pipes take seconds to fly.
+	assert.Equal(t, http.StatusBadRequest, checkpoint(token.Token, `{"score":5}`))
+	assert.Equal(t, http.StatusBadRequest, checkpoint(token.Token, `{"score":7}`))
+	assert.Equal(t, http.StatusBadRequest, checkpoint(token.Token, `{`))
+	assert.Equal(t, http.StatusNotFound, checkpoint("unknown", `{"score":5}`))
+
+	serve(mux, http.MethodPost, "/api/sessions/"+token.Token, "")
+	assert.Equal(t, http.StatusConflict, checkpoint(token.Token, `{"score":10}`))
+}
+
+func TestAdapter_Reveal(t *testing.T) {
+	mux := newTestServer()
+
+	// Ranked courses are revealed by checkpoints, whatever the client asks for.
+	rec := serve(mux, http.MethodPost, "/api/tokens", `{"course":"RANDOM","reveal":false}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "pipeKey")
+
+	rec = serve(mux, http.MethodPost, "/api/tokens", `{"course":"CHALLENGE"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var token struct {
+		Token   string             `json:"token"`
+		PipeKey string             `json:"pipeKey"`
+		Pipes   *adapter.PipesJSON `json:"pipes"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	assert.Empty(t, token.PipeKey)
+	if assert.NotNil(t, token.Pipes) {
+		assert.Equal(t, 0, token.Pipes.From)
+		assert.Len(t, token.Pipes.TileYs, 2*common.CheckpointInterval+1)
+	}
+
+	serve(mux, http.MethodPost, "/api/sessions/"+token.Token, "")
+	body, _ := json.Marshal(map[string]any{"displayName": "gopher", "jumpHistory": ceilingJumpHistory()})
+	rec = serve(mux, http.MethodPost, "/api/scores/"+token.Token, string(body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Replays of today's challenge publish the pipes they fly past but not the pipe key.
+	rec = serve(mux, http.MethodGet, "/api/replays/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var replay adapter.ReplayJSON
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&replay))
+	assert.Empty(t, replay.PipeKey)
+	if assert.NotNil(t, replay.Pipes) {
+		assert.Equal(t, token.Pipes.TileYs[:len(replay.Pipes.TileYs)], replay.Pipes.TileYs)
+	}
+}
+
+func TestAdapter_RegisterPlayerHandler(t *testing.T) {
+	mux := newTestServer()
+	key := strings.Repeat("k", 43)
+	register := func(body string) (int, int, string) {
+		rec := serve(mux, http.MethodPost, "/api/players", body)
+		var player struct {
+			ID          int    `json:"id"`
+			DisplayName string `json:"displayName"`
+		}
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&player))
+		}
+		return rec.Code, player.ID, player.DisplayName
+	}
+
+	status, id, name := register(`{"key":"` + key + `","displayName":"gopher"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotZero(t, id)
+	assert.Equal(t, "gopher", name)
+
+	// The same key signs in as the same player, and a new name renames it.
+	status, signedIn, name := register(`{"key":"` + key + `","displayName":"gophy"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, id, signedIn)
+	assert.Equal(t, "gophy", name)
+
+	status, _, _ = register(`{"key":"short","displayName":"gopher"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	rec := serve(mux, http.MethodPost, "/api/players", `{"key":"`+key+`","displayName":"gopher gopher"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error":"INVALID_DISPLAY_NAME","reason":"TOO_LONG","maxLength":10}`, rec.Body.String())
+
+	// Scores are listed under the name of their player.
+	submit := func(displayName, playerKey string) int {
+		rec := serve(mux, http.MethodPost, "/api/tokens", "")
+		var token struct {
+			Token string `json:"token"`
+		}
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+		serve(mux, http.MethodPost, "/api/sessions/"+token.Token, "")
+		body, _ := json.Marshal(map[string]any{"displayName": displayName, "playerKey": playerKey, "jumpHistory": ceilingJumpHistory()})
+		return serve(mux, http.MethodPost, "/api/scores/"+token.Token, string(body)).Code
+	}
+	assert.Equal(t, http.StatusNotFound, submit("someone", strings.Repeat("u", 43)))
+	// Names of scores without a player follow the name policy.
+	assert.Equal(t, http.StatusBadRequest, submit("\u200b", ""))
+	assert.Equal(t, http.StatusOK, submit("someone", key))
+	rec = serve(mux, http.MethodGet, "/api/scores", "")
+	var list struct {
+		Scores []adapter.ScoreJSON `json:"scores"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	if assert.Len(t, list.Scores, 1) {
+		assert.Equal(t, "gophy", list.Scores[0].DisplayName)
+	}
+
+	rec = serve(mux, http.MethodGet, fmt.Sprintf("/api/players/%d", id), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var profile adapter.PlayerProfileJSON
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&profile))
+	assert.Equal(t, "gophy", profile.DisplayName)
+	assert.Equal(t, common.DifficultyNormal, profile.Difficulty)
+	assert.Equal(t, 1, profile.Plays)
+	if assert.Len(t, profile.RecentRuns, 1) {
+		run := profile.RecentRuns[0]
+		assert.Equal(t, list.Scores[0].ID, run.ID)
+		assert.Equal(t, http.StatusOK, serve(mux, http.MethodGet, run.Replay, "").Code)
+	}
+	if assert.NotNil(t, profile.Bests["DAILY"]) {
+		assert.Equal(t, list.Scores[0].ID, profile.Bests["DAILY"].ID)
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve(mux, http.MethodGet, "/api/players/999", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(mux, http.MethodGet, "/api/players/gopher", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(mux, http.MethodGet, fmt.Sprintf("/api/players/%d?difficulty=INSANE", id), "").Code)
+}
+
+func TestAdapter_Difficulty(t *testing.T) {
+	mux := newTestServer()
+
+	rec := serve(mux, http.MethodPost, "/api/tokens", `{"difficulty":"HARD"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var token struct {
+		Course     common.Course     `json:"course"`
+		Difficulty common.Difficulty `json:"difficulty"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	assert.Equal(t, common.CourseRandom, token.Course)
+	assert.Equal(t, common.DifficultyHard, token.Difficulty)
+
+	rec = serve(mux, http.MethodPost, "/api/tokens", "")
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	assert.Equal(t, common.DifficultyNormal, token.Difficulty)
+
+	rec = serve(mux, http.MethodPost, "/api/tokens", `{"difficulty":"INSANE"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(mux, http.MethodGet, "/api/scores?period=DAILY&difficulty=EASY", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(mux, http.MethodGet, "/api/scores?period=DAILY&difficulty=INSANE", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdapter_RulesVersion(t *testing.T) {
+	mux := newTestServer()
+
+	// Clients without a rules version play version 1.
+	rec := serve(mux, http.MethodPost, "/api/tokens", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(mux, http.MethodPost, "/api/tokens", `{"rulesVersion":1}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(mux, http.MethodPost, "/api/tokens", `{"rulesVersion":99}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdapter_ListScoreHandler(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	a := adapter.NewAdapter(usecase.NewScoreUsecase(r, usecase.Config{}), adapter.Config{})
+	for i, score := range []int{3, 2, 2} {
+		assert.NoError(t, r.CreateScore(common.NewSubmittedScore("gopher", score, fmt.Sprintf("token-%d", i), "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	}
+	list := func(target string) (int, []adapter.ScoreJSON, string) {
+		rec := httptest.NewRecorder()
+		a.ListScoreHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var body struct {
+			Scores     []adapter.ScoreJSON `json:"scores"`
+			NextCursor string              `json:"nextCursor"`
+		}
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		}
+		return rec.Code, body.Scores, body.NextCursor
+	}
+
+	// The best view is the default.
+	status, scores, next := list("/api/scores")
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, 3, scores[0].Score)
+	}
+	assert.Empty(t, next)
+
+	status, scores, next = list("/api/scores?view=ALL&limit=2")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, scores, 2)
+	assert.NotEmpty(t, next)
+
+	status, scores, next = list("/api/scores?view=ALL&limit=2&cursor=" + next)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, 2, scores[0].Rank)
+	}
+	assert.Empty(t, next)
+
+	for _, target := range []string{
+		"/api/scores?limit=0",
+		"/api/scores?limit=ten",
+		"/api/scores?cursor=%21",
+		"/api/scores?cursor=bm90LWEtY3Vyc29y",
+		"/api/scores?view=UNKNOWN",
+	} {
+		status, _, _ := list(target)
+		assert.Equal(t, http.StatusBadRequest, status, target)
+	}
+}
+
+func TestAdapter_ReviewScore(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	for _, name := range []string{"human", "bot"} {
+		s := common.NewSubmittedScore(name, 20, "token-"+name, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersionCurrent, nil)
+		if name == "bot" {
+			s.Suspicion, s.Review = 0.8, common.ReviewPending
+		}
+		assert.NoError(t, r.CreateScore(s))
+	}
+	newMux := func(config adapter.Config) *http.ServeMux {
+		a := adapter.NewAdapter(usecase.NewScoreUsecase(r, usecase.Config{}), config)
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/scores", a.ListScoreHandler)
+		mux.HandleFunc("GET /api/replays/{id}", a.GetReplayHandler)
+		mux.HandleFunc("GET /api/admin/scores/pending", a.ListPendingScoresHandler)
+		mux.HandleFunc("GET /api/admin/scores/{id}/replay", a.GetReviewReplayHandler)
+		mux.HandleFunc("POST /api/admin/scores/{id}/review", a.ReviewScoreHandler)
+		return mux
+	}
+	mux := newMux(adapter.Config{AdminSecret: "secret"})
+	admin := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	listed := func() int {
+		rec := serve(mux, http.MethodGet, "/api/scores?view=ALL", "")
+		var body struct {
+			Scores []adapter.ScoreJSON `json:"scores"`
+		}
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return len(body.Scores)
+	}
+	assert.Equal(t, 1, listed())
+
+	assert.Equal(t, http.StatusUnauthorized, admin(http.MethodGet, "/api/admin/scores/pending", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, admin(http.MethodGet, "/api/admin/scores/pending", "wrong", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(newMux(adapter.Config{}), http.MethodGet, "/api/admin/scores/pending", "").Code)
+
+	rec := admin(http.MethodGet, "/api/admin/scores/pending", "secret", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var pending []adapter.PendingScoreJSON
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&pending))
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "bot", pending[0].DisplayName)
+		assert.Equal(t, 0.8, pending[0].Suspicion)
+		assert.Equal(t, fmt.Sprintf("/api/admin/scores/%d/replay", pending[0].ID), pending[0].Replay)
+	}
+
+	// The replay of a pending score is only served to admins.
+	assert.Equal(t, http.StatusNotFound, serve(mux, http.MethodGet, "/api/replays/2", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, admin(http.MethodGet, "/api/admin/scores/2/replay", "", "").Code)
+	assert.Equal(t, http.StatusOK, admin(http.MethodGet, "/api/admin/scores/2/replay", "secret", "").Code)
+	assert.Equal(t, http.StatusConflict, admin(http.MethodGet, "/api/admin/scores/1/replay", "secret", "").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, admin(http.MethodPost, "/api/admin/scores/2/review", "", `{"approved":true}`).Code)
+	assert.Equal(t, http.StatusConflict, admin(http.MethodPost, "/api/admin/scores/1/review", "secret", `{"approved":true}`).Code)
+	assert.Equal(t, http.StatusNotFound, admin(http.MethodPost, "/api/admin/scores/3/review", "secret", `{"approved":true}`).Code)
+	assert.Equal(t, http.StatusBadRequest, admin(http.MethodPost, "/api/admin/scores/x/review", "secret", `{"approved":true}`).Code)
+	assert.Equal(t, http.StatusOK, admin(http.MethodPost, "/api/admin/scores/2/review", "secret", `{"approved":true}`).Code)
+	assert.Equal(t, 2, listed())
+	assert.Equal(t, http.StatusOK, serve(mux, http.MethodGet, "/api/replays/2", "").Code)
+}
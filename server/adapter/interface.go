@@ -7,17 +7,58 @@ import (
 )
 
 type Usecase interface {
-	RegisterScore(displayName string, score int) error
-	RegisterSession(token, pipeKey string) error
-	ListScore(period string) ([]*common.Score, error)
+	RegisterPlayer(key, displayName string) (*common.Player, error)
+	RegisterScore(token, displayName, playerKey string, score int, jumpHistory []int) (id int, err error)
+	RegisterSession(board common.Board, rulesVersion common.RulesVersion, ghostID int) (session *common.Session, ghost *common.Score, err error)
+	ListScore(board common.Board, view common.View, period string, after *common.ScoreCursor, limit int) (scores []*common.Score, next *common.ScoreCursor, err error)
+	ListScoreAround(id int, view common.View, period string) (scores []*common.Score, entry *common.Score, err error)
 	CalcScore(jumpHistory []int, token string) (int, error)
-	FinishSession(token string) error
+	// FinishSession returns the token to submit the score of the session with, which is a new one for signed sessions.
+	FinishSession(token string) (string, error)
+	RecordCheckpoint(token string, score int) (*common.PipeSegment, error)
+	RevealPipes(session *common.Session, ghost *common.Score) *common.PipeSegment
+	GetReplay(id int) (*common.Score, error)
+	GetPlayerProfile(id int, board common.Board) (*common.PlayerProfile, error)
+	// ListPendingScores returns the flagged scores waiting for a review, oldest first.
+	ListPendingScores(limit int) ([]*common.Score, error)
+	// GetReviewReplay returns the replay of a flagged score, which GetReplay hides unless it is approved.
+	GetReviewReplay(id int) (*common.Score, error)
+	// ReviewScore lists the flagged score on the boards if it is approved, or keeps it hidden.
+	ReviewScore(id int, approved bool) error
 }
 
 type Repository interface {
-	CreateScore(displayName string, score int) error
-	CreateSession(token, pipeKey string) error
-	ListScore(startTime time.Time, limit int) ([]*common.Score, error)
+	CreateScore(score *common.Score) error
+	CreateSession(session *common.Session) error
+	CreatePlayer(player *common.Player, keyHash string) error
+	ListScore(board common.Board, view common.View, startTime time.Time, after *common.ScoreCursor, limit int) ([]*common.Score, error)
+	ListScoreAround(board common.Board, view common.View, startTime time.Time, score *common.Score, span int) ([]*common.Score, error)
+	GetScore(id int) (*common.Score, error)
 	GetSession(token string) (*common.Session, error)
+	GetPlayer(id int) (*common.Player, error)
+	GetPlayerByKey(keyHash string) (*common.Player, error)
+	GetPlayerBestScore(board common.Board, playerID int, startTime time.Time) (*common.Score, error)
+	GetPlayerStats(board common.Board, playerID int) (*common.PlayerStats, error)
+	ListPlayerScores(board common.Board, playerID int, limit int) ([]*common.Score, error)
+	UpdatePlayerName(id int, displayName string) error
 	UpdateSessionFinishedAt(token string) error
+	UpdateSessionScored(token string) error
+	// SpendToken records the id of a signed session token as spent until expiresAt,
+	// returning ErrSessionAlreadyScored if it already is. Expired records are deleted.
+	SpendToken(id string, expiresAt time.Time) error
+	// IsTokenSpent reports whether the id of a signed session token is recorded as spent and not expired.
+	IsTokenSpent(id string) (bool, error)
+	// FinishToken records the id of a signed session token as finished until expiresAt,
+	// returning ErrSessionFinished if it already is. Expired records are deleted.
+	FinishToken(id string, expiresAt time.Time) error
+	// CreateCheckpoint records a checkpoint of the session token and deletes the checkpoints of any session
+	// that arrived before deleteBefore.
+	CreateCheckpoint(token string, checkpoint *common.Checkpoint, deleteBefore time.Time) error
+	// ListCheckpoints returns the checkpoints of the session token in the order of their scores.
+	ListCheckpoints(token string) ([]*common.Checkpoint, error)
+	// ListReviewScores returns the scores in the review in the order they were created.
+	ListReviewScores(review common.Review, limit int) ([]*common.Score, error)
+	UpdateScoreReview(id int, review common.Review) error
+	// HasJumpHistory reports whether a score of the course of the pipe key has the jump history.
+	HasJumpHistory(pipeKey string, jumpHistory []int) (bool, error)
 }
@@ -0,0 +1,66 @@
+// Command migrate applies the schema migrations in storage/<dialect>/migrations.
+//
+//	go run ./server/cmd/migrate -dialect mysql up
+//	go run ./server/cmd/migrate -dialect d1 -local down -steps 1
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/ponyo877/flappy-ranking/server/database"
+	"github.com/ponyo877/flappy-ranking/server/migration"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+)
+
+var (
+	dialect  = flag.String("dialect", "d1", "database to migrate: d1 or mysql")
+	d1Name   = flag.String("database", "flappy-ranking", "D1 database name passed to wrangler")
+	d1Local  = flag.Bool("local", false, "migrate the local D1 database instead of the remote one")
+	downStep = flag.Int("steps", 1, "number of migrations to revert with down, 0 reverts all")
+)
+
+func main() {
+	flag.Parse()
+	command := flag.Arg(0)
+	if command == "" {
+		command = "up"
+	}
+
+	migrations, err := migration.Load(repository.Dialect(*dialect))
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	var driver migration.Driver
+	switch repository.Dialect(*dialect) {
+	case repository.DialectD1:
+		driver = newWranglerDriver(*d1Name, *d1Local)
+	case repository.DialectMySQL:
+		db, err := database.NewMySQL()
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		defer db.Close()
+		driver = migration.NewSQLDriver(db, repository.DialectMySQL)
+	}
+
+	var done []*migration.Migration
+	switch command {
+	case "up":
+		done, err = migration.Up(driver, migrations)
+	case "down":
+		done, err = migration.Down(driver, migrations, *downStep)
+	default:
+		log.Fatalf("unknown command %q: use up or down", command)
+	}
+	for _, m := range done {
+		log.Printf("%s %04d_%s", command, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+	if len(done) == 0 {
+		log.Printf("no migrations to %s", command)
+	}
+}
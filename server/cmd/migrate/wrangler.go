@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ponyo877/flappy-ranking/server/migration"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+)
+
+// wranglerDriver migrates a D1 database through the wrangler CLI,
+// since D1 is not reachable from outside of Workers.
+type wranglerDriver struct {
+	database string
+	local    bool
+}
+
+func newWranglerDriver(database string, local bool) migration.Driver {
+	return &wranglerDriver{database: database, local: local}
+}
+
+func (d *wranglerDriver) Init() error {
+	_, err := d.execute("--command", migration.CreateTableQuery(repository.DialectD1))
+	return err
+}
+
+func (d *wranglerDriver) Versions() ([]int, error) {
+	out, err := d.execute("--command", "SELECT version FROM schema_migrations ORDER BY version", "--json")
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Results []struct {
+			Version int `json:"version"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(out, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse wrangler output: %w", err)
+	}
+	var versions []int
+	for _, r := range results {
+		for _, row := range r.Results {
+			versions = append(versions, row.Version)
+		}
+	}
+	return versions, nil
+}
+
+func (d *wranglerDriver) Apply(m *migration.Migration) error {
+	return d.executeFile(fmt.Sprintf("%s;\nINSERT INTO schema_migrations (version) VALUES (%d);\n", m.Up, m.Version))
+}
+
+func (d *wranglerDriver) Revert(m *migration.Migration) error {
+	return d.executeFile(fmt.Sprintf("%s;\nDELETE FROM schema_migrations WHERE version = %d;\n", m.Down, m.Version))
+}
+
+func (d *wranglerDriver) executeFile(script string) error {
+	f, err := os.CreateTemp("", "migration-*.sql")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(strings.Join(migration.Statements(script), ";\n") + ";\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	_, err = d.execute("--file", f.Name())
+	return err
+}
+
+func (d *wranglerDriver) execute(args ...string) ([]byte, error) {
+	location := "--remote"
+	if d.local {
+		location = "--local"
+	}
+	args = append([]string{"wrangler", "d1", "execute", d.database, location, "--yes"}, args...)
+	cmd := exec.Command("npx", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("wrangler %s: %w", strings.Join(args[1:], " "), err)
+	}
+	return out, nil
+}
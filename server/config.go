@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/usecase"
+)
+
+// loadConfig reads the configuration of the server from the environment variables returned by getenv.
+func loadConfig(getenv func(string) string) (usecase.Config, adapter.Config) {
+	usecaseConfig := usecase.Config{
+		ChallengeSecret:    getenv("CHALLENGE_SECRET"),
+		NameBlocklist:      usecase.ParseNameBlocklist(getenv("NAME_BLOCKLIST")),
+		SessionSecret:      getenv("SESSION_SECRET"),
+		MaxJumps:           atoiEnv(getenv, "MAX_JUMPS"),
+		MaxFrames:          atoiEnv(getenv, "MAX_FRAMES"),
+		SuspicionThreshold: floatEnv(getenv, "SUSPICION_THRESHOLD"),
+	}
+	adapterConfig := adapter.Config{
+		MaxBodyBytes: int64(atoiEnv(getenv, "MAX_BODY_BYTES")),
+		AdminSecret:  getenv("ADMIN_SECRET"),
+	}
+	if usecaseConfig.ChallengeSecret == "" {
+		log.Printf("CHALLENGE_SECRET is not set, the daily challenge is disabled")
+	}
+	return usecaseConfig, adapterConfig
+}
+
+// atoiEnv returns the integer in the environment variable, or 0 (the default) if it is not set or not an integer.
+func atoiEnv(getenv func(string) string, name string) int {
+	v := getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return 0
+	}
+	return n
+}
+
+// floatEnv returns the number in the environment variable, or 0 (the default) if it is not set or not a number.
+func floatEnv(getenv func(string) string, name string) float64 {
+	v := getenv(name)
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return 0
+	}
+	return f
+}
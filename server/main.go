@@ -11,6 +11,7 @@ import (
 	"github.com/ponyo877/flappy-ranking/server/repository"
 	"github.com/ponyo877/flappy-ranking/server/usecase"
 	"github.com/syumai/workers"
+	"github.com/syumai/workers/cloudflare"
 
 	_ "github.com/syumai/workers/cloudflare/d1" // register driver
 )
@@ -24,14 +25,21 @@ func main() {
 		return
 	}
 
-	repository := repository.NewScoreRepository(db)
-	usecase := usecase.NewScoreUsecase(repository)
-	adapter := adapter.NewAdapter(usecase)
+	repository := repository.NewScoreRepository(db, repository.DialectD1)
+	usecaseConfig, adapterConfig := loadConfig(getenv)
+	usecase := usecase.NewScoreUsecase(repository, usecaseConfig)
+	adapter := adapter.NewAdapter(usecase, adapterConfig)
 
-	http.HandleFunc("POST /api/tokens", adapter.GenerateTokenHandler)
-	http.HandleFunc("GET /api/scores", adapter.ListScoreHandler)
-	http.HandleFunc("POST /api/scores/{token}", adapter.RegisterScoreHandler)
-	http.HandleFunc("POST /api/sessions/{token}", adapter.FinishSessionHandler)
+	registerRoutes(http.DefaultServeMux, adapter)
 
 	workers.Serve(nil)
 }
+
+// getenv returns the environment variable of the Worker, or "" if it is not set.
+func getenv(name string) string {
+	v := cloudflare.GetBinding(name)
+	if v.IsUndefined() {
+		return ""
+	}
+	return v.String()
+}
@@ -0,0 +1,78 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/database"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/ponyo877/flappy-ranking/server/usecase"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+var (
+	addr      = flag.String("addr", ":8080", "address to listen on")
+	staticDir = flag.String("static", "", "directory of the client assets to serve (optional)")
+	inMemory  = flag.Bool("memory", false, "keep scores and sessions in memory instead of MySQL")
+)
+
+func main() {
+	flag.Parse()
+
+	var repo adapter.Repository
+	if *inMemory {
+		repo = repository.NewMemoryRepository()
+	} else {
+		db, err := database.NewMySQL()
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		defer db.Close()
+		repo = repository.NewScoreRepository(db, repository.DialectMySQL)
+	}
+
+	usecaseConfig, adapterConfig := loadConfig(os.Getenv)
+	usecase := usecase.NewScoreUsecase(repo, usecaseConfig)
+	adapter := adapter.NewAdapter(usecase, adapterConfig)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, adapter)
+	if *staticDir != "" {
+		mux.Handle("GET /", http.FileServer(http.Dir(*staticDir)))
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("listening on %s", *addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shut down gracefully: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/ponyo877/flappy-ranking/storage"
+)
+
+// Migration is a numbered schema change loaded from storage/<dialect>/migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Driver applies migrations to a database and records them in the schema_migrations table.
+type Driver interface {
+	// Init creates the schema_migrations table if it does not exist.
+	Init() error
+	// Versions returns the versions recorded in schema_migrations.
+	Versions() ([]int, error)
+	// Apply runs m.Up and records m.Version.
+	Apply(m *Migration) error
+	// Revert runs m.Down and removes m.Version.
+	Revert(m *Migration) error
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations of the dialect in ascending version order.
+func Load(dialect repository.Dialect) ([]*Migration, error) {
+	dir := path.Join(string(dialect), "migrations")
+	entries, err := fs.ReadDir(storage.Migrations, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unknown dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		match := fileNamePattern.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(storage.Migrations, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s, %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every migration that is not recorded yet and returns them.
+func Up(d Driver, migrations []*Migration) ([]*Migration, error) {
+	applied, err := appliedVersions(d)
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := d.Apply(m); err != nil {
+			return done, fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down reverts the latest steps applied migrations, or all of them if steps is 0, and returns them.
+func Down(d Driver, migrations []*Migration, steps int) ([]*Migration, error) {
+	applied, err := appliedVersions(d)
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if err := d.Revert(m); err != nil {
+			return done, fmt.Errorf("failed to revert migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func appliedVersions(d Driver) (map[int]bool, error) {
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	versions, err := d.Versions()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// Statements splits a migration script into single statements.
+// Statements are separated by semicolons, which must not appear inside them.
+func Statements(script string) []string {
+	var statements []string
+	for _, s := range strings.Split(script, ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	return statements
+}
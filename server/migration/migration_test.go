@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite" // register driver
+)
+
+func TestLoad(t *testing.T) {
+	for _, dialect := range []repository.Dialect{repository.DialectD1, repository.DialectMySQL} {
+		t.Run(string(dialect), func(t *testing.T) {
+			migrations, err := Load(dialect)
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+			for i, m := range migrations {
+				assert.Equal(t, i+1, m.Version)
+				assert.NotEmpty(t, Statements(m.Up))
+				assert.NotEmpty(t, Statements(m.Down))
+			}
+		})
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrations, err := Load(repository.DialectD1)
+	require.NoError(t, err)
+	d := NewSQLDriver(db, repository.DialectD1)
+
+	done, err := Up(d, migrations)
+	require.NoError(t, err)
+	assert.Len(t, done, len(migrations))
+	done, err = Up(d, migrations)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	done, err = Down(d, migrations, 1)
+	require.NoError(t, err)
+	if assert.Len(t, done, 1) {
+		assert.Equal(t, migrations[len(migrations)-1].Version, done[0].Version)
+	}
+	done, err = Down(d, migrations, 0)
+	require.NoError(t, err)
+	assert.Len(t, done, len(migrations)-1)
+
+	versions, err := d.Versions()
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
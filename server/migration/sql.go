@@ -0,0 +1,81 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/ponyo877/flappy-ranking/server/repository"
+)
+
+var createTableQueries = map[repository.Dialect]string{
+	repository.DialectD1: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    applied_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+)`,
+	repository.DialectMySQL: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INT       PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+}
+
+// CreateTableQuery returns the DDL of the schema_migrations table.
+func CreateTableQuery(dialect repository.Dialect) string {
+	return createTableQueries[dialect]
+}
+
+// SQLDriver migrates a database reachable through database/sql.
+type SQLDriver struct {
+	db      *sql.DB
+	dialect repository.Dialect
+}
+
+func NewSQLDriver(db *sql.DB, dialect repository.Dialect) Driver {
+	return &SQLDriver{db: db, dialect: dialect}
+}
+
+func (d *SQLDriver) Init() error {
+	_, err := d.db.Exec(CreateTableQuery(d.dialect))
+	return err
+}
+
+func (d *SQLDriver) Versions() ([]int, error) {
+	rows, err := d.db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (d *SQLDriver) Apply(m *Migration) error {
+	if err := d.exec(m.Up); err != nil {
+		return err
+	}
+	_, err := d.db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.Version)
+	return err
+}
+
+func (d *SQLDriver) Revert(m *Migration) error {
+	if err := d.exec(m.Down); err != nil {
+		return err
+	}
+	_, err := d.db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	return err
+}
+
+func (d *SQLDriver) exec(script string) error {
+	for _, s := range Statements(script) {
+		if _, err := d.db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
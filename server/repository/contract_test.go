@@ -0,0 +1,572 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/database"
+	"github.com/ponyo877/flappy-ranking/server/migration"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite" // register driver
+)
+
+// testRepositoryContract checks the behavior every adapter.Repository must share.
+func testRepositoryContract(t *testing.T, newRepository func(t *testing.T) adapter.Repository) {
+	t.Run("ListScore ranks ties", func(t *testing.T) {
+		r := newRepository(t)
+		for _, s := range []struct {
+			name  string
+			score int
+		}{{"a", 5}, {"b", 9}, {"c", 5}, {"d", 3}} {
+			require.NoError(t, r.CreateScore(common.NewSubmittedScore(s.name, s.score, "token-"+s.name, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		}
+
+		scores, err := r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		type row struct {
+			Rank  int
+			Name  string
+			Score int
+		}
+		got := make([]row, len(scores))
+		for i, s := range scores {
+			got[i] = row{s.Rank, s.DisplayName, s.Score}
+		}
+		assert.Equal(t, []row{{1, "b", 9}, {2, "a", 5}, {2, "c", 5}, {4, "d", 3}}, got)
+
+		scores, err = r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, time.Time{}, nil, 2)
+		require.NoError(t, err)
+		assert.Len(t, scores, 2)
+	})
+
+	t.Run("ListScore pages", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+		for _, s := range []struct {
+			name  string
+			score int
+		}{{"a", 9}, {"b", 7}, {"c", 7}, {"d", 7}, {"e", 5}} {
+			require.NoError(t, r.CreateScore(common.NewSubmittedScore(s.name, s.score, "token-"+s.name, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		}
+		type row struct {
+			Rank int
+			Name string
+		}
+		var got []row
+		var after *common.ScoreCursor
+		for page := 0; page < 3; page++ {
+			scores, err := r.ListScore(board, common.ViewAll, time.Time{}, after, 2)
+			require.NoError(t, err)
+			for _, s := range scores {
+				got = append(got, row{s.Rank, s.DisplayName})
+			}
+			if len(scores) == 0 {
+				break
+			}
+			after = common.NewScoreCursor(scores[len(scores)-1])
+		}
+		// The tie of b, c and d crosses the page boundary.
+		assert.Equal(t, []row{{1, "a"}, {2, "b"}, {2, "c"}, {2, "d"}, {5, "e"}}, got)
+
+		scores, err := r.ListScore(board, common.ViewAll, time.Time{}, after, 2)
+		require.NoError(t, err)
+		assert.Empty(t, scores)
+	})
+
+	t.Run("ListScore filters by startTime", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, []int{32, 64})))
+
+		scores, err := r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, time.Now().Add(-time.Hour), nil, 10)
+		require.NoError(t, err)
+		if assert.Len(t, scores, 1) {
+			assert.WithinDuration(t, time.Now(), scores[0].CreatedAt, 2*time.Second)
+		}
+
+		scores, err = r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, time.Now().Add(time.Hour), nil, 10)
+		require.NoError(t, err)
+		assert.Empty(t, scores)
+	})
+
+	t.Run("ListScore filters by course", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token-a", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("b", 2, "token-b", "challenge", common.CourseChallenge, common.DifficultyNormal, common.RulesVersion1, nil)))
+
+		scores, err := r.ListScore(common.NewBoard(common.CourseChallenge, common.DifficultyNormal), common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		if assert.Len(t, scores, 1) {
+			assert.Equal(t, "b", scores[0].DisplayName)
+			assert.Equal(t, 1, scores[0].Rank)
+		}
+	})
+
+	t.Run("ListScore filters by difficulty", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token-a", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("b", 2, "token-b", "pipeKey", common.CourseRandom, common.DifficultyHard, common.RulesVersion1, nil)))
+
+		scores, err := r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyHard), common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		if assert.Len(t, scores, 1) {
+			assert.Equal(t, "b", scores[0].DisplayName)
+			assert.Equal(t, 1, scores[0].Rank)
+		}
+	})
+
+	t.Run("ListScoreAround", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+		entries := make(map[string]*common.Score)
+		for _, s := range []struct {
+			name  string
+			score int
+		}{{"a", 9}, {"b", 7}, {"c", 7}, {"d", 7}, {"e", 5}, {"f", 3}, {"g", 1}} {
+			score := common.NewSubmittedScore(s.name, s.score, "token-"+s.name, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+			require.NoError(t, r.CreateScore(score))
+			require.NotZero(t, score.ID)
+			entries[s.name] = score
+		}
+		type row struct {
+			Rank int
+			Name string
+		}
+		rows := func(scores []*common.Score) []row {
+			got := make([]row, len(scores))
+			for i, s := range scores {
+				got[i] = row{s.Rank, s.DisplayName}
+			}
+			return got
+		}
+
+		scores, err := r.ListScoreAround(board, common.ViewAll, time.Time{}, entries["d"], 2)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{2, "b"}, {2, "c"}, {2, "d"}, {5, "e"}, {6, "f"}}, rows(scores))
+
+		scores, err = r.ListScoreAround(board, common.ViewAll, time.Time{}, entries["a"], 2)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{1, "a"}, {2, "b"}, {2, "c"}}, rows(scores))
+
+		scores, err = r.ListScoreAround(board, common.ViewAll, time.Time{}, entries["g"], 1)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{6, "f"}, {7, "g"}}, rows(scores))
+
+		// Not on the board in the period
+		scores, err = r.ListScoreAround(board, common.ViewAll, time.Now().Add(time.Hour), entries["d"], 2)
+		require.NoError(t, err)
+		assert.Empty(t, scores)
+		scores, err = r.ListScoreAround(common.NewBoard(common.CourseChallenge, common.DifficultyNormal), common.ViewAll, time.Time{}, entries["d"], 2)
+		require.NoError(t, err)
+		assert.Empty(t, scores)
+	})
+
+	t.Run("ListScore keeps the best score of each player", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+		for i, s := range []struct {
+			name  string
+			score int
+		}{{"a", 5}, {"b", 7}, {"a", 9}, {"a", 9}, {"c", 7}, {"b", 3}} {
+			require.NoError(t, r.CreateScore(common.NewSubmittedScore(s.name, s.score, "token-"+strconv.Itoa(i), "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		}
+		type row struct {
+			Rank  int
+			Name  string
+			Score int
+		}
+		rows := func(scores []*common.Score) []row {
+			got := make([]row, len(scores))
+			for i, s := range scores {
+				got[i] = row{s.Rank, s.DisplayName, s.Score}
+			}
+			return got
+		}
+
+		scores, err := r.ListScore(board, common.ViewBest, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{1, "a", 9}, {2, "b", 7}, {2, "c", 7}}, rows(scores))
+
+		scores, err = r.ListScore(board, common.ViewBest, time.Time{}, common.NewScoreCursor(scores[0]), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{2, "b", 7}, {2, "c", 7}}, rows(scores))
+
+		scores, err = r.ListScore(board, common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []row{{1, "a", 9}, {1, "a", 9}, {3, "b", 7}, {3, "c", 7}, {5, "a", 5}, {6, "b", 3}}, rows(scores))
+	})
+
+	t.Run("ListScoreAround the best score of the player", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+		var entries []*common.Score
+		for _, s := range []struct {
+			name  string
+			score int
+		}{{"a", 9}, {"b", 7}, {"b", 3}, {"c", 5}} {
+			score := common.NewSubmittedScore(s.name, s.score, "token-"+strconv.Itoa(len(entries)), "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+			require.NoError(t, r.CreateScore(score))
+			entries = append(entries, score)
+		}
+
+		// The worse score of b is centred on its best one.
+		scores, err := r.ListScoreAround(board, common.ViewBest, time.Time{}, entries[2], 1)
+		require.NoError(t, err)
+		ids := make([]int, len(scores))
+		for i, s := range scores {
+			ids[i] = s.ID
+		}
+		assert.Equal(t, []int{entries[0].ID, entries[1].ID, entries[3].ID}, ids)
+		assert.Equal(t, entries[1].PlayerKey, scores[1].PlayerKey)
+	})
+
+	t.Run("CreateScore rejects a second score of a session", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, []int{32})))
+		assert.Error(t, r.CreateScore(common.NewSubmittedScore("b", 2, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, []int{32})))
+	})
+
+	t.Run("GetScore", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, []int{32, 64})))
+		scores, err := r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		require.Len(t, scores, 1)
+
+		s, err := r.GetScore(scores[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "a", s.DisplayName)
+		assert.Equal(t, 1, s.Score)
+		assert.Equal(t, "token", s.Token)
+		assert.Equal(t, "pipeKey", s.PipeKey)
+		assert.Equal(t, common.CourseRandom, s.Course)
+		assert.Equal(t, common.DifficultyNormal, s.Difficulty)
+		assert.Equal(t, common.RulesVersion1, s.RulesVersion)
+		assert.Equal(t, []int{32, 64}, s.JumpHistory)
+
+		_, err = r.GetScore(scores[0].ID + 1)
+		assert.ErrorIs(t, err, adapter.ErrScoreNotFound)
+	})
+
+	t.Run("HasJumpHistory", func(t *testing.T) {
+		r := newRepository(t)
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 1, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, []int{32, 64})))
+
+		for _, tt := range []struct {
+			pipeKey     string
+			jumpHistory []int
+			want        bool
+		}{
+			{"pipeKey", []int{32, 64}, true},
+			{"pipeKey", []int{32, 96}, false},
+			{"other", []int{32, 64}, false},
+		} {
+			got, err := r.HasJumpHistory(tt.pipeKey, tt.jumpHistory)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got, "%s %v", tt.pipeKey, tt.jumpHistory)
+		}
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.GetSession("missing")
+		assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+
+		require.NoError(t, r.CreateSession(common.NewSession("token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, common.SessionCreated, time.Time{}, time.Time{})))
+		s, err := r.GetSession("token")
+		require.NoError(t, err)
+		assert.Equal(t, "token", s.Token)
+		assert.Equal(t, "pipeKey", s.PipeKey)
+		assert.Equal(t, common.CourseRandom, s.Course)
+		assert.Equal(t, common.DifficultyNormal, s.Difficulty)
+		assert.Equal(t, common.RulesVersion1, s.RulesVersion)
+		assert.Equal(t, s.CreatedAt, s.FinishedAt)
+		assert.WithinDuration(t, time.Now(), s.CreatedAt, 2*time.Second)
+
+		require.NoError(t, r.UpdateSessionFinishedAt("token"))
+		s, err = r.GetSession("token")
+		require.NoError(t, err)
+		assert.False(t, s.FinishedAt.Before(s.CreatedAt))
+	})
+
+	t.Run("Player", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.GetPlayerByKey("hash")
+		assert.ErrorIs(t, err, adapter.ErrPlayerNotFound)
+		assert.ErrorIs(t, r.UpdatePlayerName(1, "b"), adapter.ErrPlayerNotFound)
+
+		player := common.NewPlayer(0, "a", time.Time{})
+		require.NoError(t, r.CreatePlayer(player, "hash"))
+		require.NotZero(t, player.ID)
+		assert.WithinDuration(t, time.Now(), player.CreatedAt, 2*time.Second)
+		assert.Error(t, r.CreatePlayer(common.NewPlayer(0, "b", time.Time{}), "hash"))
+
+		p, err := r.GetPlayerByKey("hash")
+		require.NoError(t, err)
+		assert.Equal(t, player, p)
+
+		score := common.NewSubmittedScore("a", 1, "token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+		score.SetPlayer(player)
+		require.NoError(t, r.CreateScore(score))
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 2, "token-name", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+
+		// Renaming the player relists its scores only.
+		require.NoError(t, r.UpdatePlayerName(player.ID, "b"))
+		p, err = r.GetPlayerByKey("hash")
+		require.NoError(t, err)
+		assert.Equal(t, "b", p.DisplayName)
+		s, err := r.GetScore(score.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b", s.DisplayName)
+		assert.Equal(t, player.ID, s.PlayerID)
+		assert.Equal(t, common.IDPlayerKey(player.ID), s.PlayerKey)
+		s, err = r.GetScore(score.ID + 1)
+		require.NoError(t, err)
+		assert.Equal(t, "a", s.DisplayName)
+		assert.Zero(t, s.PlayerID)
+	})
+
+	t.Run("Player scores", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+
+		_, err := r.GetPlayer(1)
+		assert.ErrorIs(t, err, adapter.ErrPlayerNotFound)
+
+		player := common.NewPlayer(0, "a", time.Time{})
+		require.NoError(t, r.CreatePlayer(player, "hash"))
+		p, err := r.GetPlayer(player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, player, p)
+
+		stats, err := r.GetPlayerStats(board, player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &common.PlayerStats{}, stats)
+		_, err = r.GetPlayerBestScore(board, player.ID, time.Time{})
+		assert.ErrorIs(t, err, adapter.ErrScoreNotFound)
+
+		var ids []int
+		for i, s := range []struct {
+			score int
+			board common.Board
+		}{{3, board}, {8, board}, {8, board}, {4, board}, {100, common.NewBoard(common.CourseRandom, common.DifficultyHard)}} {
+			score := common.NewSubmittedScore("a", s.score, "token-"+strconv.Itoa(i), "pipeKey", s.board.Course, s.board.Difficulty, common.RulesVersion1, nil)
+			score.SetPlayer(player)
+			require.NoError(t, r.CreateScore(score))
+			ids = append(ids, score.ID)
+		}
+		// Another player with the same name
+		require.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 50, "token-name", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+		// A flagged score of the player, which is hidden from the profile like from the boards
+		flagged := common.NewSubmittedScore("a", 200, "token-flagged", "pipeKey", board.Course, board.Difficulty, common.RulesVersion1, nil)
+		flagged.SetPlayer(player)
+		flagged.Suspicion, flagged.Review = 0.75, common.ReviewPending
+		require.NoError(t, r.CreateScore(flagged))
+
+		stats, err = r.GetPlayerStats(board, player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &common.PlayerStats{Plays: 4, AverageScore: 5.75}, stats)
+
+		best, err := r.GetPlayerBestScore(board, player.ID, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, ids[1], best.ID)
+		assert.Equal(t, 8, best.Score)
+		_, err = r.GetPlayerBestScore(board, player.ID, time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, adapter.ErrScoreNotFound)
+
+		scores, err := r.ListPlayerScores(board, player.ID, 3)
+		require.NoError(t, err)
+		got := make([]int, len(scores))
+		for i, s := range scores {
+			got[i] = s.ID
+		}
+		assert.Equal(t, []int{ids[3], ids[2], ids[1]}, got)
+	})
+
+	t.Run("Session status", func(t *testing.T) {
+		r := newRepository(t)
+
+		assert.ErrorIs(t, r.UpdateSessionFinishedAt("missing"), adapter.ErrSessionNotFound)
+		assert.ErrorIs(t, r.UpdateSessionScored("missing"), adapter.ErrSessionNotFound)
+
+		require.NoError(t, r.CreateSession(common.NewSession("token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, common.SessionCreated, time.Time{}, time.Time{})))
+		assertStatus(t, r, "token", common.SessionCreated)
+		assert.ErrorIs(t, r.UpdateSessionScored("token"), adapter.ErrSessionNotFinished)
+
+		require.NoError(t, r.UpdateSessionFinishedAt("token"))
+		assertStatus(t, r, "token", common.SessionFinished)
+		finished, err := r.GetSession("token")
+		require.NoError(t, err)
+		// Finishing again succeeds and keeps the first finish time.
+		require.NoError(t, r.UpdateSessionFinishedAt("token"))
+		again, err := r.GetSession("token")
+		require.NoError(t, err)
+		assert.Equal(t, finished.FinishedAt, again.FinishedAt)
+
+		require.NoError(t, r.UpdateSessionScored("token"))
+		assertStatus(t, r, "token", common.SessionScored)
+		assert.ErrorIs(t, r.UpdateSessionScored("token"), adapter.ErrSessionAlreadyScored)
+		assert.ErrorIs(t, r.UpdateSessionFinishedAt("token"), adapter.ErrSessionAlreadyScored)
+	})
+
+	t.Run("SpendToken", func(t *testing.T) {
+		r := newRepository(t)
+		expiresAt := time.Now().Add(time.Hour)
+
+		spent, err := r.IsTokenSpent("spent")
+		require.NoError(t, err)
+		assert.False(t, spent)
+		require.NoError(t, r.SpendToken("spent", expiresAt))
+		assert.ErrorIs(t, r.SpendToken("spent", expiresAt), adapter.ErrSessionAlreadyScored)
+		spent, err = r.IsTokenSpent("spent")
+		require.NoError(t, err)
+		assert.True(t, spent)
+
+		// Expired tokens are forgotten by the next spend.
+		require.NoError(t, r.SpendToken("expired", time.Now().Add(-time.Hour)))
+		require.NoError(t, r.SpendToken("other", expiresAt))
+		assert.NoError(t, r.SpendToken("expired", expiresAt))
+		assert.ErrorIs(t, r.SpendToken("spent", expiresAt), adapter.ErrSessionAlreadyScored)
+	})
+
+	t.Run("FinishToken", func(t *testing.T) {
+		r := newRepository(t)
+		expiresAt := time.Now().Add(time.Hour)
+
+		require.NoError(t, r.FinishToken("finished", expiresAt))
+		assert.ErrorIs(t, r.FinishToken("finished", expiresAt), adapter.ErrSessionFinished)
+		// Finishing and spending are recorded apart.
+		require.NoError(t, r.SpendToken("finished", expiresAt))
+
+		// Expired tokens are forgotten by the next finish.
+		require.NoError(t, r.FinishToken("expired", time.Now().Add(-time.Hour)))
+		require.NoError(t, r.FinishToken("other", expiresAt))
+		assert.NoError(t, r.FinishToken("expired", expiresAt))
+	})
+
+	t.Run("Checkpoints", func(t *testing.T) {
+		r := newRepository(t)
+		now := time.Now()
+		hourAgo := now.Add(-time.Hour)
+
+		require.NoError(t, r.CreateCheckpoint("old", common.NewCheckpoint(5, now.Add(-2*time.Hour)), time.Time{}))
+		require.NoError(t, r.CreateCheckpoint("token", common.NewCheckpoint(10, now.Add(time.Millisecond)), hourAgo))
+		require.NoError(t, r.CreateCheckpoint("token", common.NewCheckpoint(5, now), hourAgo))
+		assert.Error(t, r.CreateCheckpoint("token", common.NewCheckpoint(5, now), hourAgo))
+
+		checkpoints, err := r.ListCheckpoints("token")
+		require.NoError(t, err)
+		assert.Equal(t, []*common.Checkpoint{
+			common.NewCheckpoint(5, now.Truncate(time.Millisecond)),
+			common.NewCheckpoint(10, now.Add(time.Millisecond).Truncate(time.Millisecond)),
+		}, checkpoints)
+
+		// The checkpoints of other sessions that arrived before deleteBefore are gone.
+		checkpoints, err = r.ListCheckpoints("old")
+		require.NoError(t, err)
+		assert.Empty(t, checkpoints)
+	})
+
+	t.Run("Review", func(t *testing.T) {
+		r := newRepository(t)
+		board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+		for _, name := range []string{"human", "bot", "other bot"} {
+			s := common.NewSubmittedScore(name, 5, "token-"+name, "pipeKey", board.Course, board.Difficulty, common.RulesVersion1, nil)
+			if name != "human" {
+				s.Suspicion, s.Review = 0.75, common.ReviewPending
+			}
+			require.NoError(t, r.CreateScore(s))
+		}
+
+		// Pending scores are hidden from both views.
+		for _, view := range []common.View{common.ViewAll, common.ViewBest} {
+			scores, err := r.ListScore(board, view, time.Time{}, nil, 10)
+			require.NoError(t, err)
+			require.Len(t, scores, 1)
+			assert.Equal(t, "human", scores[0].DisplayName)
+		}
+		pending, err := r.ListReviewScores(common.ReviewPending, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, "bot", pending[0].DisplayName)
+		assert.Equal(t, 0.75, pending[0].Suspicion)
+		assert.Equal(t, common.ReviewPending, pending[0].Review)
+
+		require.NoError(t, r.UpdateScoreReview(pending[0].ID, common.ReviewApproved))
+		require.NoError(t, r.UpdateScoreReview(pending[0].ID, common.ReviewApproved))
+		require.NoError(t, r.UpdateScoreReview(pending[1].ID, common.ReviewRejected))
+		assert.ErrorIs(t, r.UpdateScoreReview(pending[1].ID+1, common.ReviewApproved), adapter.ErrScoreNotFound)
+
+		scores, err := r.ListScore(board, common.ViewAll, time.Time{}, nil, 10)
+		require.NoError(t, err)
+		assert.Len(t, scores, 2)
+		pending, err = r.ListReviewScores(common.ReviewPending, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		s, err := r.GetScore(scores[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, common.ReviewNone, s.Review)
+	})
+}
+
+func assertStatus(t *testing.T, r adapter.Repository, token string, want common.SessionStatus) {
+	t.Helper()
+	s, err := r.GetSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, want, s.Status)
+}
+
+func migrate(t *testing.T, db *sql.DB, dialect repository.Dialect) {
+	migrations, err := migration.Load(dialect)
+	require.NoError(t, err)
+	_, err = migration.Up(migration.NewSQLDriver(db, dialect), migrations)
+	require.NoError(t, err)
+}
+
+func TestMemoryRepository_Contract(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) adapter.Repository {
+		return repository.NewMemoryRepository()
+	})
+}
+
+func TestScoreRepository_D1Contract(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) adapter.Repository {
+		db, err := sql.Open("sqlite", ":memory:")
+		require.NoError(t, err)
+		// Every connection to ":memory:" opens a new database.
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		migrate(t, db, repository.DialectD1)
+		return repository.NewScoreRepository(db, repository.DialectD1)
+	})
+}
+
+// TestScoreRepository_MySQLContract runs against the database configured by the MYSQL_* variables,
+// e.g. the one started by storage/mysql/compose.yaml. Pending migrations are applied and all rows are deleted.
+func TestScoreRepository_MySQLContract(t *testing.T) {
+	if os.Getenv("MYSQL_HOST") == "" {
+		t.Skip("MYSQL_HOST is not set")
+	}
+	testRepositoryContract(t, func(t *testing.T) adapter.Repository {
+		db, err := database.NewMySQL()
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		migrate(t, db, repository.DialectMySQL)
+		for _, table := range []string{"scores", "sessions", "players", "spent_tokens", "finished_tokens", "checkpoints"} {
+			_, err := db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+		return repository.NewScoreRepository(db, repository.DialectMySQL)
+	})
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+)
+
+// Dialect is the SQL dialect spoken by the database behind ScoreRepository.
+type Dialect string
+
+const (
+	// DialectD1 is Cloudflare D1 (SQLite). Timestamps are stored as Unix seconds in INTEGER columns.
+	DialectD1 Dialect = "d1"
+	// DialectMySQL is MySQL. Timestamps are stored in TIMESTAMP columns.
+	DialectMySQL Dialect = "mysql"
+)
+
+// timeValue converts t into the value bound to a timestamp column.
+func (d Dialect) timeValue(t time.Time) any {
+	if d == DialectMySQL {
+		return t.Truncate(time.Second)
+	}
+	return t.Unix()
+}
+
+// dbTime scans a timestamp column written by any Dialect.
+type dbTime time.Time
+
+func (t *dbTime) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = dbTime(v)
+	case int64:
+		*t = dbTime(time.Unix(v, 0))
+	case float64:
+		*t = dbTime(time.Unix(int64(v), 0))
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		parsed, err := time.Parse(time.DateTime, v)
+		if err != nil {
+			return err
+		}
+		*t = dbTime(parsed)
+	default:
+		return fmt.Errorf("unsupported timestamp type %T", src)
+	}
+	return nil
+}
+
+func (t dbTime) Time() time.Time {
+	return time.Time(t)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+// sessionStateError returns the error for a session whose status does not allow the transition.
+func sessionStateError(status common.SessionStatus) error {
+	switch status {
+	case common.SessionCreated:
+		return adapter.ErrSessionNotFinished
+	case common.SessionScored:
+		return adapter.ErrSessionAlreadyScored
+	default:
+		return fmt.Errorf("unexpected session status %q", status)
+	}
+}
@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+// MemoryRepository is an in-memory adapter.Repository for local development and tests.
+// It mirrors ScoreRepository, including its second-precision timestamps.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	scores   []Score
+	sessions map[string]Session
+	players  []Player
+	// spentTokens maps the ids of spent session tokens to their expiry.
+	spentTokens map[string]time.Time
+	// finishedTokens maps the ids of finished session tokens to their expiry.
+	finishedTokens map[string]time.Time
+	checkpoints    map[string][]common.Checkpoint
+	now            func() time.Time
+}
+
+func NewMemoryRepository() adapter.Repository {
+	return newMemoryRepository(time.Now)
+}
+
+func newMemoryRepository(now func() time.Time) *MemoryRepository {
+	return &MemoryRepository{
+		sessions:       make(map[string]Session),
+		spentTokens:    make(map[string]time.Time),
+		finishedTokens: make(map[string]time.Time),
+		checkpoints:    make(map[string][]common.Checkpoint),
+		now:            now,
+	}
+}
+
+func (r *MemoryRepository) CreateScore(score *common.Score) error {
+	jumpHistory, err := json.Marshal(score.JumpHistory)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.scores {
+		if s.Token.String == score.Token {
+			return fmt.Errorf("duplicate score for token %s", score.Token)
+		}
+	}
+	score.ID = len(r.scores) + 1
+	r.scores = append(r.scores, Score{
+		ID:           score.ID,
+		DisplayName:  score.DisplayName,
+		Score:        score.Score,
+		PlayerKey:    score.PlayerKey,
+		PlayerID:     sql.NullInt64{Int64: int64(score.PlayerID), Valid: score.PlayerID != 0},
+		Token:        sql.NullString{String: score.Token, Valid: true},
+		PipeKey:      sql.NullString{String: score.PipeKey, Valid: true},
+		Course:       string(score.Course),
+		Difficulty:   string(score.Difficulty),
+		RulesVersion: int(score.RulesVersion),
+		JumpHistory:  sql.NullString{String: string(jumpHistory), Valid: true},
+		Suspicion:    score.Suspicion,
+		Review:       string(score.Review),
+		CreatedAt:    dbTime(r.now().Truncate(time.Second)),
+	})
+	return nil
+}
+
+func (r *MemoryRepository) CreateSession(session *common.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := dbTime(r.now().Truncate(time.Second))
+	r.sessions[session.Token] = Session{
+		ID:           len(r.sessions) + 1,
+		Token:        session.Token,
+		PipeKey:      session.PipeKey,
+		Course:       string(session.Course),
+		Difficulty:   string(session.Difficulty),
+		RulesVersion: int(session.RulesVersion),
+		Status:       string(common.SessionCreated),
+		FinishedAt:   now,
+		CreatedAt:    now,
+	}
+	return nil
+}
+
+func (r *MemoryRepository) CreatePlayer(player *common.Player, keyHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.players {
+		if p.KeyHash == keyHash {
+			return fmt.Errorf("duplicate player for key hash %s", keyHash)
+		}
+	}
+	now := r.now().Truncate(time.Second)
+	player.ID = len(r.players) + 1
+	player.CreatedAt = now
+	r.players = append(r.players, Player{
+		ID:          player.ID,
+		KeyHash:     keyHash,
+		DisplayName: player.DisplayName,
+		CreatedAt:   dbTime(now),
+		UpdatedAt:   dbTime(now),
+	})
+	return nil
+}
+
+func (r *MemoryRepository) GetPlayer(id int) (*common.Player, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id < 1 || id > len(r.players) {
+		return nil, adapter.ErrPlayerNotFound
+	}
+	p := r.players[id-1]
+	return common.NewPlayer(p.ID, p.DisplayName, p.CreatedAt.Time()), nil
+}
+
+func (r *MemoryRepository) GetPlayerByKey(keyHash string) (*common.Player, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.players {
+		if p.KeyHash == keyHash {
+			return common.NewPlayer(p.ID, p.DisplayName, p.CreatedAt.Time()), nil
+		}
+	}
+	return nil, adapter.ErrPlayerNotFound
+}
+
+func (r *MemoryRepository) UpdatePlayerName(id int, displayName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || id > len(r.players) {
+		return adapter.ErrPlayerNotFound
+	}
+	r.players[id-1].DisplayName = displayName
+	r.players[id-1].UpdatedAt = dbTime(r.now().Truncate(time.Second))
+	for i := range r.scores {
+		if r.scores[i].PlayerID.Valid && int(r.scores[i].PlayerID.Int64) == id {
+			r.scores[i].DisplayName = displayName
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) GetPlayerBestScore(board common.Board, playerID int, startTime time.Time) (*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best *common.Score
+	for _, s := range r.playerScores(board, playerID) {
+		if s.CreatedAt.Time().Before(startTime.Truncate(time.Second)) {
+			continue
+		}
+		if best == nil || s.Score > best.Score {
+			best = common.NewScore(s.ID, 0, s.DisplayName, s.Score, s.CreatedAt.Time())
+		}
+	}
+	if best == nil {
+		return nil, adapter.ErrScoreNotFound
+	}
+	return best, nil
+}
+
+func (r *MemoryRepository) GetPlayerStats(board common.Board, playerID int) (*common.PlayerStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var stats common.PlayerStats
+	total := 0
+	for _, s := range r.playerScores(board, playerID) {
+		stats.Plays++
+		total += s.Score
+	}
+	if stats.Plays > 0 {
+		stats.AverageScore = float64(total) / float64(stats.Plays)
+	}
+	return &stats, nil
+}
+
+func (r *MemoryRepository) ListPlayerScores(board common.Board, playerID int, limit int) ([]*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var scores []*common.Score
+	// Scores are stored in the order they were created.
+	filtered := r.playerScores(board, playerID)
+	for i := len(filtered) - 1; i >= 0 && len(scores) < limit; i-- {
+		s := filtered[i]
+		scores = append(scores, common.NewScore(s.ID, 0, s.DisplayName, s.Score, s.CreatedAt.Time()))
+	}
+	return scores, nil
+}
+
+// playerScores returns the listed scores of the player on the board in the order they were created.
+func (r *MemoryRepository) playerScores(board common.Board, playerID int) []Score {
+	var filtered []Score
+	for _, s := range r.scores {
+		if s.PlayerID.Valid && int(s.PlayerID.Int64) == playerID && s.Course == string(board.Course) && s.Difficulty == string(board.Difficulty) && common.Review(s.Review).IsListed() {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+func (r *MemoryRepository) ListScore(board common.Board, view common.View, startTime time.Time, after *common.ScoreCursor, limit int) ([]*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scores := r.rankedScores(board, view, startTime)
+	if after != nil {
+		i := 0
+		for i < len(scores) && !after.Before(scores[i].Score, scores[i].ID) {
+			i++
+		}
+		scores = scores[i:]
+	}
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+func (r *MemoryRepository) ListScoreAround(board common.Board, view common.View, startTime time.Time, score *common.Score, span int) ([]*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scores := r.rankedScores(board, view, startTime)
+	for i, s := range scores {
+		if s.ID == score.ID || (view == common.ViewBest && s.PlayerKey == score.PlayerKey) {
+			return scores[max(i-span, 0):min(i+span+1, len(scores))], nil
+		}
+	}
+	return nil, nil
+}
+
+// rankedScores returns the scores of the view on the board since startTime in the order of ScoreRepository with their ranks.
+func (r *MemoryRepository) rankedScores(board common.Board, view common.View, startTime time.Time) []*common.Score {
+	var filtered []Score
+	for _, s := range r.scores {
+		if s.Course == string(board.Course) && s.Difficulty == string(board.Difficulty) && !s.CreatedAt.Time().Before(startTime.Truncate(time.Second)) && common.Review(s.Review).IsListed() {
+			filtered = append(filtered, s)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Score > filtered[j].Score
+	})
+	if view == common.ViewBest {
+		// Keep the first score of each player in the order of the board.
+		seen := make(map[string]bool)
+		best := filtered[:0]
+		for _, s := range filtered {
+			if !seen[s.PlayerKey] {
+				seen[s.PlayerKey] = true
+				best = append(best, s)
+			}
+		}
+		filtered = best
+	}
+
+	var scores []*common.Score
+	rank := 1
+	previousScore := -1
+	previousRank := 0
+
+	for _, s := range filtered {
+		currentRank := previousRank
+		if s.Score != previousScore {
+			currentRank = rank
+			previousRank = rank
+		}
+		score := common.NewScore(s.ID, currentRank, s.DisplayName, s.Score, s.CreatedAt.Time())
+		score.PlayerKey = s.PlayerKey
+		scores = append(scores, score)
+		previousScore = s.Score
+		rank++
+	}
+	return scores
+}
+
+func (r *MemoryRepository) GetSession(token string) (*common.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.sessions[token]
+	if !ok {
+		return nil, adapter.ErrSessionNotFound
+	}
+	return common.NewSession(s.Token, s.PipeKey, common.Course(s.Course), common.Difficulty(s.Difficulty), common.RulesVersion(s.RulesVersion), common.SessionStatus(s.Status), s.FinishedAt.Time(), s.CreatedAt.Time()), nil
+}
+
+func (r *MemoryRepository) UpdateSessionFinishedAt(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[token]
+	if !ok {
+		return adapter.ErrSessionNotFound
+	}
+	switch common.SessionStatus(s.Status) {
+	case common.SessionScored:
+		return adapter.ErrSessionAlreadyScored
+	case common.SessionFinished:
+		// Only the first finish counts.
+		return nil
+	}
+	s.Status = string(common.SessionFinished)
+	s.FinishedAt = dbTime(r.now().Truncate(time.Second))
+	r.sessions[token] = s
+	return nil
+}
+
+func (r *MemoryRepository) UpdateSessionScored(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[token]
+	if !ok {
+		return adapter.ErrSessionNotFound
+	}
+	if s.Status != string(common.SessionFinished) {
+		return sessionStateError(common.SessionStatus(s.Status))
+	}
+	s.Status = string(common.SessionScored)
+	r.sessions[token] = s
+	return nil
+}
+
+func (r *MemoryRepository) SpendToken(id string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for spent, expiry := range r.spentTokens {
+		if expiry.Before(now) {
+			delete(r.spentTokens, spent)
+		}
+	}
+	if _, ok := r.spentTokens[id]; ok {
+		return adapter.ErrSessionAlreadyScored
+	}
+	r.spentTokens[id] = expiresAt
+	return nil
+}
+
+func (r *MemoryRepository) IsTokenSpent(id string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	expiry, ok := r.spentTokens[id]
+	return ok && !expiry.Before(r.now()), nil
+}
+
+func (r *MemoryRepository) FinishToken(id string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for finished, expiry := range r.finishedTokens {
+		if expiry.Before(now) {
+			delete(r.finishedTokens, finished)
+		}
+	}
+	if _, ok := r.finishedTokens[id]; ok {
+		return adapter.ErrSessionFinished
+	}
+	r.finishedTokens[id] = expiresAt
+	return nil
+}
+
+func (r *MemoryRepository) CreateCheckpoint(token string, checkpoint *common.Checkpoint, deleteBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for t, checkpoints := range r.checkpoints {
+		kept := checkpoints[:0]
+		for _, c := range checkpoints {
+			if !c.ArrivedAt.Before(deleteBefore) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.checkpoints, t)
+			continue
+		}
+		r.checkpoints[t] = kept
+	}
+	for _, c := range r.checkpoints[token] {
+		if c.Score == checkpoint.Score {
+			return fmt.Errorf("duplicate checkpoint %d for token %s", checkpoint.Score, token)
+		}
+	}
+	// Like ScoreRepository, arrival times are kept to the millisecond.
+	r.checkpoints[token] = append(r.checkpoints[token], *common.NewCheckpoint(checkpoint.Score, checkpoint.ArrivedAt.Truncate(time.Millisecond)))
+	return nil
+}
+
+func (r *MemoryRepository) ListCheckpoints(token string) ([]*common.Checkpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var checkpoints []*common.Checkpoint
+	for _, c := range r.checkpoints[token] {
+		checkpoints = append(checkpoints, common.NewCheckpoint(c.Score, c.ArrivedAt))
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Score < checkpoints[j].Score
+	})
+	return checkpoints, nil
+}
+
+func (r *MemoryRepository) ListReviewScores(review common.Review, limit int) ([]*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var scores []*common.Score
+	for _, s := range r.scores {
+		if len(scores) == limit {
+			break
+		}
+		if common.Review(s.Review) != review {
+			continue
+		}
+		score, err := s.toScore()
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
+
+func (r *MemoryRepository) HasJumpHistory(pipeKey string, jumpHistory []int) (bool, error) {
+	encoded, err := json.Marshal(jumpHistory)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.scores {
+		if s.PipeKey.String == pipeKey && s.JumpHistory.String == string(encoded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryRepository) UpdateScoreReview(id int, review common.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.scores {
+		if r.scores[i].ID == id {
+			r.scores[i].Review = string(review)
+			return nil
+		}
+	}
+	return adapter.ErrScoreNotFound
+}
+
+func (r *MemoryRepository) GetScore(id int) (*common.Score, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.scores {
+		if s.ID == id {
+			return s.toScore()
+		}
+	}
+	return nil, adapter.ErrScoreNotFound
+}
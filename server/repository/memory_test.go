@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository_ListScore(t *testing.T) {
+	now := time.Date(2024, 11, 16, 12, 0, 0, 0, time.UTC)
+	r := newMemoryRepository(func() time.Time { return now })
+
+	now = now.Add(-48 * time.Hour)
+	assert.NoError(t, r.CreateScore(common.NewSubmittedScore("old", 100, "token-old", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	now = now.Add(48 * time.Hour)
+	assert.NoError(t, r.CreateScore(common.NewSubmittedScore("a", 5, "token-a", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	assert.NoError(t, r.CreateScore(common.NewSubmittedScore("b", 9, "token-b", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	assert.NoError(t, r.CreateScore(common.NewSubmittedScore("c", 5, "token-c", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	assert.NoError(t, r.CreateScore(common.NewSubmittedScore("d", 3, "token-d", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+
+	type want struct {
+		rank int
+		name string
+	}
+	tests := []struct {
+		name      string
+		startTime time.Time
+		limit     int
+		want      []want
+	}{
+		{
+			name:      "all time",
+			startTime: time.Time{},
+			limit:     10,
+			want:      []want{{1, "old"}, {2, "b"}, {3, "a"}, {3, "c"}, {5, "d"}},
+		},
+		{
+			name:      "since yesterday",
+			startTime: now.Add(-24 * time.Hour),
+			limit:     10,
+			want:      []want{{1, "b"}, {2, "a"}, {2, "c"}, {4, "d"}},
+		},
+		{
+			name:      "limit",
+			startTime: now.Add(-24 * time.Hour),
+			limit:     2,
+			want:      []want{{1, "b"}, {2, "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, err := r.ListScore(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.ViewAll, tt.startTime, nil, tt.limit)
+			assert.NoError(t, err)
+			got := make([]want, len(scores))
+			for i, s := range scores {
+				got[i] = want{s.Rank, s.DisplayName}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryRepository_UpdateSessionFinishedAt(t *testing.T) {
+	now := time.Date(2024, 11, 16, 12, 0, 0, 0, time.UTC)
+	r := newMemoryRepository(func() time.Time { return now })
+	assert.NoError(t, r.CreateSession(common.NewSession("token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, common.SessionCreated, time.Time{}, now)))
+
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, r.UpdateSessionFinishedAt("token"))
+	// A later finish doesn't widen the play time.
+	now = now.Add(time.Minute)
+	assert.NoError(t, r.UpdateSessionFinishedAt("token"))
+	s, err := r.GetSession("token")
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-time.Minute), s.FinishedAt)
+}
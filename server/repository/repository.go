@@ -2,6 +2,8 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/ponyo877/flappy-ranking/common"
@@ -9,91 +11,516 @@ import (
 )
 
 type ScoreRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewScoreRepository(db *sql.DB) adapter.Repository {
-	return &ScoreRepository{db: db}
+func NewScoreRepository(db *sql.DB, dialect Dialect) adapter.Repository {
+	return &ScoreRepository{db: db, dialect: dialect}
 }
 
 type Score struct {
+	ID           int            `db:"id"`
+	DisplayName  string         `db:"display_name"`
+	Score        int            `db:"score"`
+	PlayerKey    string         `db:"player_key"`
+	PlayerID     sql.NullInt64  `db:"player_id"`
+	Token        sql.NullString `db:"token"`
+	PipeKey      sql.NullString `db:"pipe_key"`
+	Course       string         `db:"course"`
+	Difficulty   string         `db:"difficulty"`
+	RulesVersion int            `db:"rules_version"`
+	JumpHistory  sql.NullString `db:"jump_history"`
+	Suspicion    float64        `db:"suspicion"`
+	Review       string         `db:"review"`
+	CreatedAt    dbTime         `db:"created_at"`
+}
+
+func (s *Score) toScore() (*common.Score, error) {
+	var jumpHistory []int
+	if s.JumpHistory.Valid {
+		if err := json.Unmarshal([]byte(s.JumpHistory.String), &jumpHistory); err != nil {
+			return nil, err
+		}
+	}
+	score := common.NewSubmittedScore(s.DisplayName, s.Score, s.Token.String, s.PipeKey.String, common.Course(s.Course), common.Difficulty(s.Difficulty), common.RulesVersion(s.RulesVersion), jumpHistory)
+	score.ID = s.ID
+	score.PlayerKey = s.PlayerKey
+	score.PlayerID = int(s.PlayerID.Int64)
+	score.Suspicion = s.Suspicion
+	score.Review = common.Review(s.Review)
+	score.CreatedAt = s.CreatedAt.Time()
+	return score, nil
+}
+
+type Player struct {
 	ID          int    `db:"id"`
+	KeyHash     string `db:"key_hash"`
 	DisplayName string `db:"display_name"`
-	Score       int    `db:"score"`
-	CreatedAt   uint64 `db:"created_at"`
+	CreatedAt   dbTime `db:"created_at"`
+	UpdatedAt   dbTime `db:"updated_at"`
 }
 
 type Session struct {
-	ID         int    `db:"id"`
-	Token      string `db:"token"`
-	PipeKey    string `db:"pipe_key"`
-	FinishedAt uint64 `db:"finished_at"`
-	CreatedAt  uint64 `db:"created_at"`
+	ID           int    `db:"id"`
+	Token        string `db:"token"`
+	PipeKey      string `db:"pipe_key"`
+	Course       string `db:"course"`
+	Difficulty   string `db:"difficulty"`
+	RulesVersion int    `db:"rules_version"`
+	Status       string `db:"status"`
+	FinishedAt   dbTime `db:"finished_at"`
+	CreatedAt    dbTime `db:"created_at"`
+}
+
+// CreateScore inserts the score and sets its ID.
+func (r *ScoreRepository) CreateScore(score *common.Score) error {
+	query := "INSERT INTO scores (display_name, score, player_key, player_id, token, pipe_key, course, difficulty, rules_version, jump_history, suspicion, review, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	jumpHistory, err := json.Marshal(score.JumpHistory)
+	if err != nil {
+		return err
+	}
+	now := r.dialect.timeValue(time.Now())
+	playerID := sql.NullInt64{Int64: int64(score.PlayerID), Valid: score.PlayerID != 0}
+	id, err := r.insertID(query, score.DisplayName, score.Score, score.PlayerKey, playerID, score.Token, score.PipeKey, score.Course, score.Difficulty, score.RulesVersion, string(jumpHistory), score.Suspicion, score.Review, now)
+	if err != nil {
+		return err
+	}
+	score.ID = id
+	return nil
+}
+
+func (r *ScoreRepository) CreateSession(session *common.Session) error {
+	query := "INSERT INTO sessions (token, pipe_key, course, difficulty, rules_version, status, finished_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	now := r.dialect.timeValue(time.Now())
+	if _, err := r.db.Exec(query, session.Token, session.PipeKey, session.Course, session.Difficulty, session.RulesVersion, common.SessionCreated, now, now); err != nil {
+		return err
+	}
+	return nil
 }
 
-func (r *ScoreRepository) CreateScore(displayName string, score int) error {
-	query := "INSERT INTO scores (display_name, score, created_at) VALUES (?, ?, ?)"
-	now := time.Now().Unix()
-	if _, err := r.db.Exec(query, displayName, score, now); err != nil {
+// CreatePlayer inserts the player with the hash of its key and sets its ID and creation time.
+func (r *ScoreRepository) CreatePlayer(player *common.Player, keyHash string) error {
+	query := "INSERT INTO players (key_hash, display_name, created_at, updated_at) VALUES (?, ?, ?, ?)"
+	now := time.Now().Truncate(time.Second)
+	id, err := r.insertID(query, keyHash, player.DisplayName, r.dialect.timeValue(now), r.dialect.timeValue(now))
+	if err != nil {
 		return err
 	}
+	player.ID = id
+	player.CreatedAt = now
 	return nil
 }
 
-func (r *ScoreRepository) CreateSession(token, pipeKey string) error {
-	query := "INSERT INTO sessions (token, pipe_key, finished_at, created_at) VALUES (?, ?, ?, ?)"
-	now := time.Now().Unix()
-	if _, err := r.db.Exec(query, token, pipeKey, now, now); err != nil {
+func (r *ScoreRepository) GetPlayer(id int) (*common.Player, error) {
+	query := "SELECT id, display_name, created_at FROM players WHERE id = ?"
+	var p Player
+	if err := r.db.QueryRow(query, id).Scan(&p.ID, &p.DisplayName, &p.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, adapter.ErrPlayerNotFound
+		}
+		return nil, err
+	}
+	return common.NewPlayer(p.ID, p.DisplayName, p.CreatedAt.Time()), nil
+}
+
+func (r *ScoreRepository) GetPlayerByKey(keyHash string) (*common.Player, error) {
+	query := "SELECT id, display_name, created_at FROM players WHERE key_hash = ?"
+	var p Player
+	if err := r.db.QueryRow(query, keyHash).Scan(&p.ID, &p.DisplayName, &p.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, adapter.ErrPlayerNotFound
+		}
+		return nil, err
+	}
+	return common.NewPlayer(p.ID, p.DisplayName, p.CreatedAt.Time()), nil
+}
+
+// UpdatePlayerName renames the player and relists its scores under the new name.
+func (r *ScoreRepository) UpdatePlayerName(id int, displayName string) error {
+	// D1 has no transactions. The player is renamed last so that a failed rename keeps the old name and can be retried.
+	if _, err := r.db.Exec("UPDATE scores SET display_name = ? WHERE player_id = ?", displayName, id); err != nil {
 		return err
 	}
+	now := r.dialect.timeValue(time.Now())
+	n, err := r.execAffected("UPDATE players SET display_name = ?, updated_at = ? WHERE id = ?", displayName, now, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return adapter.ErrPlayerNotFound
+	}
 	return nil
 }
 
-func (r *ScoreRepository) ListScore(startDate time.Time, limit int) ([]*common.Score, error) {
-	query := "SELECT id, display_name, score, created_at FROM scores WHERE created_at >= ? ORDER BY score DESC LIMIT ?"
-	rows, err := r.db.Query(query, startDate.Unix(), limit)
-	if err != nil && err != sql.ErrNoRows {
+// GetPlayerBestScore returns the best listed score of the player on the board since startTime.
+// Ties go to the earlier score, as on the best view of the board.
+func (r *ScoreRepository) GetPlayerBestScore(board common.Board, playerID int, startTime time.Time) (*common.Score, error) {
+	query := `SELECT id, display_name, score, created_at
+FROM scores
+WHERE player_id = ? AND course = ? AND difficulty = ? AND created_at >= ? AND ` + listedCondition + `
+ORDER BY score DESC, id ASC
+LIMIT 1`
+	var s Score
+	if err := r.db.QueryRow(query, playerID, board.Course, board.Difficulty, r.dialect.timeValue(startTime)).Scan(&s.ID, &s.DisplayName, &s.Score, &s.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, adapter.ErrScoreNotFound
+		}
+		return nil, err
+	}
+	return common.NewScore(s.ID, 0, s.DisplayName, s.Score, s.CreatedAt.Time()), nil
+}
+
+// GetPlayerStats returns the number and average of the listed scores of the player on the board.
+func (r *ScoreRepository) GetPlayerStats(board common.Board, playerID int) (*common.PlayerStats, error) {
+	query := "SELECT COUNT(*), COALESCE(AVG(score), 0) FROM scores WHERE player_id = ? AND course = ? AND difficulty = ? AND " + listedCondition
+	var stats common.PlayerStats
+	if err := r.db.QueryRow(query, playerID, board.Course, board.Difficulty).Scan(&stats.Plays, &stats.AverageScore); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ListPlayerScores returns the latest listed scores of the player on the board, newest first.
+func (r *ScoreRepository) ListPlayerScores(board common.Board, playerID int, limit int) ([]*common.Score, error) {
+	query := `SELECT id, display_name, score, created_at
+FROM scores
+WHERE player_id = ? AND course = ? AND difficulty = ? AND ` + listedCondition + `
+ORDER BY created_at DESC, id DESC
+LIMIT ?`
+	rows, err := r.db.Query(query, playerID, board.Course, board.Difficulty, limit)
+	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var scores []*common.Score
-	rank := 1
-	previousScore := -1
-	previousRank := 0
-
 	for rows.Next() {
 		var s Score
 		if err := rows.Scan(&s.ID, &s.DisplayName, &s.Score, &s.CreatedAt); err != nil {
 			return nil, err
 		}
-		currentRank := previousRank
-		if s.Score != previousScore {
-			currentRank = rank
-			previousRank = rank
+		scores = append(scores, common.NewScore(s.ID, 0, s.DisplayName, s.Score, s.CreatedAt.Time()))
+	}
+	return scores, rows.Err()
+}
+
+// listedCondition selects the scores whose review lists them on the boards, as common.Review.IsListed.
+const listedCondition = "review IN ('" + string(common.ReviewNone) + "', '" + string(common.ReviewApproved) + "')"
+
+// rankedQuery returns the WITH clause of a table named ranked that holds the scores of the view
+// on the board since a start time, with their rank and their position in the order of the board.
+// Its placeholders are the course, the difficulty and the start time.
+// Scores flagged as suspicious are left out until they are approved.
+//
+// Both views read the scores of the period once through idx_board_created_at_score.
+// The best view keeps the first score of each player in the order of the board with ROW_NUMBER.
+func rankedQuery(view common.View) string {
+	source := `SELECT id, display_name, score, player_key, created_at
+    FROM scores
+    WHERE course = ? AND difficulty = ? AND created_at >= ? AND ` + listedCondition
+	if view == common.ViewBest {
+		source = `SELECT id, display_name, score, player_key, created_at FROM (
+        SELECT id, display_name, score, player_key, created_at,
+            ROW_NUMBER() OVER (PARTITION BY player_key ORDER BY score DESC, id ASC) AS player_position
+        FROM scores
+        WHERE course = ? AND difficulty = ? AND created_at >= ? AND ` + listedCondition + `
+    ) AS player_scores
+    WHERE player_position = 1`
+	}
+	return `WITH board_scores AS (
+    ` + source + `
+), ranked AS (
+    SELECT id, display_name, score, player_key, created_at,
+        RANK() OVER (ORDER BY score DESC) AS score_rank,
+        ROW_NUMBER() OVER (ORDER BY score DESC, id ASC) AS position
+    FROM board_scores
+)
+`
+}
+
+// ListScore returns a page of the view of the board since startDate that starts after the cursor,
+// or at the top if it is nil. Scores are ranked over the whole board so that ties keep their rank across pages.
+func (r *ScoreRepository) ListScore(board common.Board, view common.View, startDate time.Time, after *common.ScoreCursor, limit int) ([]*common.Score, error) {
+	query := rankedQuery(view) + "SELECT id, display_name, score, player_key, created_at, score_rank FROM ranked"
+	args := []any{board.Course, board.Difficulty, r.dialect.timeValue(startDate)}
+	if after != nil {
+		query += " WHERE score < ? OR (score = ? AND id > ?)"
+		args = append(args, after.Score, after.Score, after.ID)
+	}
+	query += " ORDER BY score DESC, id ASC LIMIT ?"
+	args = append(args, limit)
+	return r.queryRankedScores(query, args...)
+}
+
+// ListScoreAround returns the scores ranked within span places of the entry of the score, including the entry.
+// The entry is the score itself, or the best score of its player in the best view.
+// It returns no scores if the entry is not on the board in the period.
+func (r *ScoreRepository) ListScoreAround(board common.Board, view common.View, startTime time.Time, score *common.Score, span int) ([]*common.Score, error) {
+	target := "SELECT position FROM ranked WHERE id = ?"
+	targetArg := any(score.ID)
+	if view == common.ViewBest {
+		target = "SELECT position FROM ranked WHERE player_key = ?"
+		targetArg = score.PlayerKey
+	}
+	query := rankedQuery(view) + `, target AS (
+    ` + target + `
+)
+SELECT ranked.id, ranked.display_name, ranked.score, ranked.player_key, ranked.created_at, ranked.score_rank
+FROM ranked, target
+WHERE ranked.position BETWEEN target.position - ? AND target.position + ?
+ORDER BY ranked.position`
+	return r.queryRankedScores(query, board.Course, board.Difficulty, r.dialect.timeValue(startTime), targetArg, span, span)
+}
+
+// queryRankedScores runs a query selecting id, display_name, score, player_key, created_at and rank.
+func (r *ScoreRepository) queryRankedScores(query string, args ...any) ([]*common.Score, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []*common.Score
+	for rows.Next() {
+		var s Score
+		var rank int
+		if err := rows.Scan(&s.ID, &s.DisplayName, &s.Score, &s.PlayerKey, &s.CreatedAt, &rank); err != nil {
+			return nil, err
+		}
+		score := common.NewScore(s.ID, rank, s.DisplayName, s.Score, s.CreatedAt.Time())
+		score.PlayerKey = s.PlayerKey
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
+
+func (r *ScoreRepository) GetScore(id int) (*common.Score, error) {
+	query := "SELECT id, display_name, score, player_key, player_id, token, pipe_key, course, difficulty, rules_version, jump_history, suspicion, review, created_at FROM scores WHERE id = ?"
+	var s Score
+	if err := r.db.QueryRow(query, id).Scan(&s.ID, &s.DisplayName, &s.Score, &s.PlayerKey, &s.PlayerID, &s.Token, &s.PipeKey, &s.Course, &s.Difficulty, &s.RulesVersion, &s.JumpHistory, &s.Suspicion, &s.Review, &s.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, adapter.ErrScoreNotFound
+		}
+		return nil, err
+	}
+	return s.toScore()
+}
+
+// ListReviewScores returns the scores in the review, oldest first.
+func (r *ScoreRepository) ListReviewScores(review common.Review, limit int) ([]*common.Score, error) {
+	query := "SELECT id, display_name, score, player_key, player_id, token, pipe_key, course, difficulty, rules_version, jump_history, suspicion, review, created_at FROM scores WHERE review = ? ORDER BY created_at, id LIMIT ?"
+	rows, err := r.db.Query(query, review, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []*common.Score
+	for rows.Next() {
+		var s Score
+		if err := rows.Scan(&s.ID, &s.DisplayName, &s.Score, &s.PlayerKey, &s.PlayerID, &s.Token, &s.PipeKey, &s.Course, &s.Difficulty, &s.RulesVersion, &s.JumpHistory, &s.Suspicion, &s.Review, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		score, err := s.toScore()
+		if err != nil {
+			return nil, err
 		}
-		scores = append(scores, common.NewScore(currentRank, s.DisplayName, s.Score, time.Unix(int64(s.CreatedAt), 0)))
-		previousScore = s.Score
-		rank++
+		scores = append(scores, score)
 	}
+	return scores, rows.Err()
+}
 
-	return scores, nil
+// HasJumpHistory compares the jump histories as they are stored, which CreateScore encodes the same way.
+func (r *ScoreRepository) HasJumpHistory(pipeKey string, jumpHistory []int) (bool, error) {
+	encoded, err := json.Marshal(jumpHistory)
+	if err != nil {
+		return false, err
+	}
+	var n int
+	query := "SELECT COUNT(*) FROM scores WHERE pipe_key = ? AND jump_history = ?"
+	if err := r.db.QueryRow(query, pipeKey, string(encoded)).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *ScoreRepository) UpdateScoreReview(id int, review common.Review) error {
+	n, err := r.execAffected("UPDATE scores SET review = ? WHERE id = ?", review, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL does not count a row that is already in the review.
+		_, err := r.GetScore(id)
+		return err
+	}
+	return nil
 }
 
 func (r *ScoreRepository) GetSession(token string) (*common.Session, error) {
-	query := "SELECT * FROM sessions WHERE token = ?"
+	query := "SELECT id, token, pipe_key, course, difficulty, rules_version, status, finished_at, created_at FROM sessions WHERE token = ?"
 	var s Session
-	if err := r.db.QueryRow(query, token).Scan(&s.ID, &s.Token, &s.PipeKey, &s.FinishedAt, &s.CreatedAt); err != nil {
+	if err := r.db.QueryRow(query, token).Scan(&s.ID, &s.Token, &s.PipeKey, &s.Course, &s.Difficulty, &s.RulesVersion, &s.Status, &s.FinishedAt, &s.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, adapter.ErrSessionNotFound
+		}
 		return nil, err
 	}
-	return common.NewSession(s.Token, s.PipeKey, time.Unix(int64(s.FinishedAt), 0), time.Unix(int64(s.CreatedAt), 0)), nil
+	return common.NewSession(s.Token, s.PipeKey, common.Course(s.Course), common.Difficulty(s.Difficulty), common.RulesVersion(s.RulesVersion), common.SessionStatus(s.Status), s.FinishedAt.Time(), s.CreatedAt.Time()), nil
 }
 
+// UpdateSessionFinishedAt finishes the session now. Only the first finish counts, so that repeating it
+// can't push the finish time later; finishing a finished session again succeeds without changing it.
 func (r *ScoreRepository) UpdateSessionFinishedAt(token string) error {
-	query := "UPDATE sessions SET finished_at = ? WHERE token = ?"
-	now := time.Now().Unix()
-	if _, err := r.db.Exec(query, now, token); err != nil {
+	query := "UPDATE sessions SET finished_at = ?, status = ? WHERE token = ? AND status = ?"
+	now := r.dialect.timeValue(time.Now())
+	n, err := r.execAffected(query, now, common.SessionFinished, token, common.SessionCreated)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		s, err := r.GetSession(token)
+		if err != nil {
+			return err
+		}
+		if s.Status == common.SessionFinished {
+			return nil
+		}
+		return sessionStateError(s.Status)
+	}
+	return nil
+}
+
+func (r *ScoreRepository) UpdateSessionScored(token string) error {
+	query := "UPDATE sessions SET status = ? WHERE token = ? AND status = ?"
+	n, err := r.execAffected(query, common.SessionScored, token, common.SessionFinished)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.sessionStateError(token)
+	}
+	return nil
+}
+
+func (r *ScoreRepository) SpendToken(id string, expiresAt time.Time) error {
+	if _, err := r.db.Exec("DELETE FROM spent_tokens WHERE expires_at < ?", r.dialect.timeValue(time.Now())); err != nil {
+		return err
+	}
+	query := "INSERT INTO spent_tokens (token_id, expires_at) VALUES (?, ?) ON CONFLICT (token_id) DO NOTHING"
+	if r.dialect == DialectMySQL {
+		query = "INSERT IGNORE INTO spent_tokens (token_id, expires_at) VALUES (?, ?)"
+	}
+	n, err := r.execAffected(query, id, r.dialect.timeValue(expiresAt))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return adapter.ErrSessionAlreadyScored
+	}
+	return nil
+}
+
+func (r *ScoreRepository) IsTokenSpent(id string) (bool, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM spent_tokens WHERE token_id = ? AND expires_at >= ?"
+	if err := r.db.QueryRow(query, id, r.dialect.timeValue(time.Now())).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *ScoreRepository) FinishToken(id string, expiresAt time.Time) error {
+	if _, err := r.db.Exec("DELETE FROM finished_tokens WHERE expires_at < ?", r.dialect.timeValue(time.Now())); err != nil {
+		return err
+	}
+	query := "INSERT INTO finished_tokens (token_id, expires_at) VALUES (?, ?) ON CONFLICT (token_id) DO NOTHING"
+	if r.dialect == DialectMySQL {
+		query = "INSERT IGNORE INTO finished_tokens (token_id, expires_at) VALUES (?, ?)"
+	}
+	n, err := r.execAffected(query, id, r.dialect.timeValue(expiresAt))
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return adapter.ErrSessionFinished
+	}
 	return nil
 }
+
+// CreateCheckpoint stores the arrival time in Unix milliseconds in every dialect,
+// since checkpoints are compared with sub-second precision.
+func (r *ScoreRepository) CreateCheckpoint(token string, checkpoint *common.Checkpoint, deleteBefore time.Time) error {
+	if _, err := r.db.Exec("DELETE FROM checkpoints WHERE arrived_at < ?", deleteBefore.UnixMilli()); err != nil {
+		return err
+	}
+	query := "INSERT INTO checkpoints (token, score, arrived_at) VALUES (?, ?, ?)"
+	_, err := r.db.Exec(query, token, checkpoint.Score, checkpoint.ArrivedAt.UnixMilli())
+	return err
+}
+
+func (r *ScoreRepository) ListCheckpoints(token string) ([]*common.Checkpoint, error) {
+	rows, err := r.db.Query("SELECT score, arrived_at FROM checkpoints WHERE token = ? ORDER BY score", token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var checkpoints []*common.Checkpoint
+	for rows.Next() {
+		var score int
+		var arrivedAt int64
+		if err := rows.Scan(&score, &arrivedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, common.NewCheckpoint(score, time.UnixMilli(arrivedAt)))
+	}
+	return checkpoints, rows.Err()
+}
+
+// sessionStateError explains why a conditional session update matched no row.
+func (r *ScoreRepository) sessionStateError(token string) error {
+	s, err := r.GetSession(token)
+	if err != nil {
+		return err
+	}
+	return sessionStateError(s.Status)
+}
+
+// insertID executes the INSERT query and returns the ID of the inserted row.
+func (r *ScoreRepository) insertID(query string, args ...any) (int, error) {
+	if r.dialect == DialectD1 {
+		// The D1 driver does not report the last insert ID.
+		var id int
+		err := r.db.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// execAffected executes the UPDATE or INSERT query and returns the number of affected rows.
+func (r *ScoreRepository) execAffected(query string, args ...any) (int64, error) {
+	if r.dialect == DialectD1 {
+		// The D1 driver does not report affected rows.
+		rows, err := r.db.Query(query+" RETURNING id", args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		var n int64
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+func registerRoutes(mux *http.ServeMux, adapter *adapter.Adapter) {
+	mux.HandleFunc("POST /api/tokens", adapter.GenerateTokenHandler)
+	mux.HandleFunc("GET /api/scores", adapter.ListScoreHandler)
+	mux.HandleFunc("GET /api/scores/{id}/around", adapter.ListScoreAroundHandler)
+	mux.HandleFunc("POST /api/scores/{token}", adapter.RegisterScoreHandler)
+	mux.HandleFunc("POST /api/players", adapter.RegisterPlayerHandler)
+	mux.HandleFunc("GET /api/players/{id}", adapter.GetPlayerHandler)
+	mux.HandleFunc("POST /api/sessions/{token}", adapter.FinishSessionHandler)
+	mux.HandleFunc("POST /api/sessions/{token}/checkpoints", adapter.RecordCheckpointHandler)
+	mux.HandleFunc("GET /api/replays/{id}", adapter.GetReplayHandler)
+	mux.HandleFunc("GET /api/admin/scores/pending", adapter.ListPendingScoresHandler)
+	mux.HandleFunc("GET /api/admin/scores/{id}/replay", adapter.GetReviewReplayHandler)
+	mux.HandleFunc("POST /api/admin/scores/{id}/review", adapter.ReviewScoreHandler)
+}
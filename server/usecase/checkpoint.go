@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+const (
+	// checkpointTolerance absorbs the latency of checkpoint reports and the second precision of session times.
+	checkpointTolerance = 2 * time.Second
+	// checkpointRetention is how long checkpoints are kept, longer than any play that can still be submitted.
+	checkpointRetention = sessionTokenTTL
+
+	// The client plays at 60 FPS, and slow devices at no less than 30 FPS, as IsValidTimeDiff allows.
+	maxFPS = 60
+	minFPS = 30
+)
+
+// RecordCheckpoint records that the unfinished session of the token has passed score pipes now,
+// and reveals the pipes up to revealAhead beyond them.
+// Checkpoints are optional, but the ones that are reported must match the replay when it is submitted.
+// A checkpoint that arrives sooner than the pipes could be flown is rejected, so that the course can't be
+// revealed faster than it is played.
+func (u *ScoreUsecase) RecordCheckpoint(token string, score int) (*common.PipeSegment, error) {
+	s, err := u.getSession(token)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != common.SessionCreated {
+		return nil, adapter.ErrSessionFinished
+	}
+	if score <= 0 || score%common.CheckpointInterval != 0 {
+		return nil, fmt.Errorf("%w: %d is not a multiple of %d", adapter.ErrInvalidCheckpoint, score, common.CheckpointInterval)
+	}
+	rules, ok := common.LookupRules(s.RulesVersion, s.Difficulty)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d %s", adapter.ErrUnsupportedRules, s.RulesVersion, s.Difficulty)
+	}
+	checkpoints, err := u.repository.ListCheckpoints(s.Token)
+	if err != nil {
+		return nil, err
+	}
+	previousFrame, previousTime := 0, s.CreatedAt
+	if n := len(checkpoints); n > 0 {
+		if checkpoints[n-1].Score >= score {
+			return nil, fmt.Errorf("%w: %d after %d", adapter.ErrInvalidCheckpoint, score, checkpoints[n-1].Score)
+		}
+		previousFrame, previousTime = common.CheckpointFrame(checkpoints[n-1].Score, rules), checkpoints[n-1].ArrivedAt
+	}
+	now := time.Now()
+	frames := common.CheckpointFrame(score, rules) - previousFrame
+	if fastest := framesDuration(frames, maxFPS) - checkpointTolerance; now.Sub(previousTime) < fastest {
+		return nil, fmt.Errorf("%w: %d arrived %v after the previous one, want at least %v", adapter.ErrInvalidCheckpoint, score, now.Sub(previousTime), fastest)
+	}
+	if err := u.repository.CreateCheckpoint(s.Token, common.NewCheckpoint(score, now), now.Add(-checkpointRetention)); err != nil {
+		return nil, err
+	}
+	return common.CoursePipes(s.PipeKey, score+1, score+revealAhead), nil
+}
+
+// validateCheckpoints checks that the replay, which ended at frame endFrame, passed the checkpoints of the session
+// at the pace they arrived: from the start of the session to each checkpoint and on to the finish,
+// the frames in between must have been played at minFPS to maxFPS, give or take checkpointTolerance.
+func validateCheckpoints(s *common.Session, checkpoints []*common.Checkpoint, endFrame int, rules common.Rules) error {
+	previousFrame, previousTime := 0, s.CreatedAt
+	check := func(frame int, at time.Time, what string) error {
+		elapsed := at.Sub(previousTime)
+		fastest := framesDuration(frame-previousFrame, maxFPS) - checkpointTolerance
+		slowest := framesDuration(frame-previousFrame, minFPS) + checkpointTolerance
+		if elapsed < fastest || elapsed > slowest {
+			return fmt.Errorf("%w: %s %v after the previous one, want %v to %v", adapter.ErrCheckpointMismatch, what, elapsed, fastest, slowest)
+		}
+		previousFrame, previousTime = frame, at
+		return nil
+	}
+	for _, c := range checkpoints {
+		frame := common.CheckpointFrame(c.Score, rules)
+		if frame > endFrame {
+			return fmt.Errorf("%w: checkpoint %d was never reached", adapter.ErrCheckpointMismatch, c.Score)
+		}
+		if err := check(frame, c.ArrivedAt, fmt.Sprintf("checkpoint %d", c.Score)); err != nil {
+			return err
+		}
+	}
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	return check(endFrame, s.FinishedAt, "finish")
+}
+
+func framesDuration(frames, fps int) time.Duration {
+	return time.Duration(frames) * time.Second / time.Duration(fps)
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreUsecase_RecordCheckpoint(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{SessionSecret: "secret"}).(*ScoreUsecase)
+	// startedSession returns the token of a signed session that started an hour ago.
+	startedSession := func(id string) string {
+		session := common.NewSession(id, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersionCurrent, common.SessionCreated, time.Time{}, time.Now().Add(-time.Hour).Truncate(time.Second))
+		token, err := u.sealSession(id, session)
+		assert.NoError(t, err)
+		return token
+	}
+	token := startedSession("session")
+
+	pipes, err := u.RecordCheckpoint(token, common.CheckpointInterval)
+	assert.NoError(t, err)
+	assert.Equal(t, common.CoursePipes("pipeKey", common.CheckpointInterval+1, common.CheckpointInterval+revealAhead), pipes)
+	for _, score := range []int{common.CheckpointInterval, common.CheckpointInterval + 1, 0} {
+		_, err = u.RecordCheckpoint(token, score)
+		assert.ErrorIs(t, err, adapter.ErrInvalidCheckpoint)
+	}
+	// The next pipes can't be flown in no time.
+	_, err = u.RecordCheckpoint(token, 2*common.CheckpointInterval)
+	assert.ErrorIs(t, err, adapter.ErrInvalidCheckpoint)
+	_, err = u.RecordCheckpoint("unknown", common.CheckpointInterval)
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+
+	checkpoints, err := r.ListCheckpoints("session")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+
+	// Checkpoints may be skipped.
+	other := startedSession("other")
+	_, err = u.RecordCheckpoint(other, 3*common.CheckpointInterval)
+	assert.NoError(t, err)
+
+	finished, err := u.FinishSession(token)
+	assert.NoError(t, err)
+	_, err = u.RecordCheckpoint(finished, 4*common.CheckpointInterval)
+	assert.ErrorIs(t, err, adapter.ErrSessionFinished)
+}
+
+func TestValidateCheckpoints(t *testing.T) {
+	rules := common.DifficultyNormal.Rules()
+	start := time.Date(2024, 11, 16, 12, 0, 0, 0, time.UTC)
+	// at returns the time at which a client playing at fps reaches the frame.
+	at := func(frame, fps int) time.Time {
+		return start.Add(framesDuration(frame, fps))
+	}
+	five := common.CheckpointFrame(5, rules)
+	ten := common.CheckpointFrame(10, rules)
+	end := ten + 100
+
+	tests := []struct {
+		name        string
+		checkpoints []*common.Checkpoint
+		finishedAt  time.Time
+		wantErr     bool
+	}{
+		{
+			name:       "no checkpoints",
+			finishedAt: at(end, 60),
+		},
+		{
+			name:        "60 FPS",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 60)), common.NewCheckpoint(10, at(ten, 60))},
+			finishedAt:  at(end, 60),
+		},
+		{
+			name:        "30 FPS",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 30)), common.NewCheckpoint(10, at(ten, 30))},
+			finishedAt:  at(end, 30),
+		},
+		{
+			name:        "late report within the tolerance",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 60).Add(time.Second)), common.NewCheckpoint(10, at(ten, 60))},
+			finishedAt:  at(end, 60),
+		},
+		{
+			name:        "skipped checkpoint",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(10, at(ten, 60))},
+			finishedAt:  at(end, 60),
+		},
+		{
+			name:        "faster than 60 FPS",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 60)), common.NewCheckpoint(10, at(ten, 90))},
+			finishedAt:  at(end, 60),
+			wantErr:     true,
+		},
+		{
+			name:        "waited between checkpoints",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 60)), common.NewCheckpoint(10, at(five, 60).Add(time.Minute))},
+			finishedAt:  at(five, 60).Add(time.Minute + 2*time.Second),
+			wantErr:     true,
+		},
+		{
+			name:        "all checkpoints at once",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(ten, 60)), common.NewCheckpoint(10, at(ten, 60))},
+			finishedAt:  at(end, 60),
+			wantErr:     true,
+		},
+		{
+			name:        "waited before finishing",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(5, at(five, 60)), common.NewCheckpoint(10, at(ten, 60))},
+			finishedAt:  at(end, 60).Add(time.Minute),
+			wantErr:     true,
+		},
+		{
+			name:        "checkpoint the replay never reached",
+			checkpoints: []*common.Checkpoint{common.NewCheckpoint(15, at(end, 60))},
+			finishedAt:  at(end, 60),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := common.NewSession("token", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersionCurrent, common.SessionFinished, tt.finishedAt, start)
+			err := validateCheckpoints(s, tt.checkpoints, end, rules)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, adapter.ErrCheckpointMismatch)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateRevealed(t *testing.T) {
+	session := func(course common.Course) *common.Session {
+		return common.NewSession("id", "pipeKey", course, common.DifficultyNormal, common.RulesVersionCurrent, common.SessionFinished, time.Time{}, time.Time{})
+	}
+	checkpoints := []*common.Checkpoint{
+		common.NewCheckpoint(common.CheckpointInterval, time.Time{}),
+		common.NewCheckpoint(3*common.CheckpointInterval, time.Time{}),
+	}
+
+	for _, course := range []common.Course{common.CourseRandom, common.CourseChallenge} {
+		assert.NoError(t, validateRevealed(session(course), nil, revealAhead))
+		assert.ErrorIs(t, validateRevealed(session(course), nil, revealAhead+1), adapter.ErrCheckpointMismatch)
+		assert.NoError(t, validateRevealed(session(course), checkpoints, 3*common.CheckpointInterval+revealAhead))
+		assert.ErrorIs(t, validateRevealed(session(course), checkpoints, 3*common.CheckpointInterval+revealAhead+1), adapter.ErrCheckpointMismatch)
+	}
+	// Ghost races fly a published course.
+	assert.NoError(t, validateRevealed(session(common.CourseGhost), nil, 100))
+}
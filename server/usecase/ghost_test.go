@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreUsecase_RegisterScore_ghost(t *testing.T) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{ChallengeSecret: "secret"})
+	// play registers the jump history played on the pipe key of a new session.
+	play := func(board common.Board, ghostID int, name string, jumpHistory func(pipeKey string) []int) (*common.Session, int, error) {
+		s, _, err := u.RegisterSession(board, common.RulesVersionCurrent, ghostID)
+		require.NoError(t, err)
+		_, err = u.FinishSession(s.Token)
+		require.NoError(t, err)
+		id, err := u.RegisterScore(s.Token, name, "", 10, jumpHistory(s.PipeKey))
+		return s, id, err
+	}
+	autopilot := func(frames int) func(string) []int {
+		return func(pipeKey string) []int { return autopilotJumpHistory(pipeKey, frames) }
+	}
+
+	for _, course := range []common.Course{common.CourseRandom, common.CourseChallenge} {
+		t.Run(string(course), func(t *testing.T) {
+			board := common.NewBoard(course, common.DifficultyNormal)
+			top, topID, err := play(board, 0, "top", autopilot(1500))
+			require.NoError(t, err)
+
+			// Submitting the jump history of the ghost unchanged copies its score.
+			ghost, _, err := play(board, topID, "copycat", func(string) []int { return autopilotJumpHistory(top.PipeKey, 1500) })
+			assert.ErrorIs(t, err, adapter.ErrCopiedReplay)
+			assert.Equal(t, top.PipeKey, ghost.PipeKey)
+			// The replay of the ghost reveals the course ahead of play, even on today's challenge.
+			assert.Equal(t, common.CourseGhost, ghost.Course)
+
+			// A play of its own is registered, but not ranked.
+			_, _, err = play(board, topID, "racer", autopilot(1400))
+			require.NoError(t, err)
+			scores, _, err := u.ListScore(board, common.ViewAll, "", nil, 0)
+			require.NoError(t, err)
+			if assert.Len(t, scores, 1) {
+				assert.Equal(t, topID, scores[0].ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+// validateJumpHistory checks that the jump history lists frames of the rules in the order they were played,
+// since the replayer silently skips every entry after one it never reaches.
+func validateJumpHistory(jumpHistory []int, rules common.Rules) error {
+	previous := common.InitialX16
+	for i, x16 := range jumpHistory {
+		switch {
+		case x16 <= common.InitialX16 || (x16-common.InitialX16)%rules.DeltaX16 != 0:
+			return &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOffGrid, Index: i}
+		case i > 0 && x16 == previous:
+			return &adapter.JumpHistoryError{Reason: adapter.JumpHistoryDuplicate, Index: i}
+		case x16 < previous:
+			return &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOutOfOrder, Index: i}
+		}
+		previous = x16
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJumpHistory(t *testing.T) {
+	rules := common.DifficultyNormal.Rules()
+	d := rules.DeltaX16
+
+	tests := []struct {
+		name        string
+		jumpHistory []int
+		want        *adapter.JumpHistoryError
+	}{
+		{"empty", nil, nil},
+		{"ascending frames", []int{d, 5 * d, 6 * d, 40 * d}, nil},
+		{"before the first frame", []int{0, d}, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOffGrid, Index: 0}},
+		{"negative", []int{-d}, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOffGrid, Index: 0}},
+		{"between frames", []int{d, 2*d + 1}, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOffGrid, Index: 1}},
+		{"duplicated", []int{d, 3 * d, 3 * d}, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryDuplicate, Index: 2}},
+		{"out of order", []int{d, 4 * d, 2 * d}, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryOutOfOrder, Index: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJumpHistory(tt.jumpHistory, rules)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, adapter.ErrInvalidJumpHistory)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeDisplayName applies the name policy and returns the display name to store.
+// The name is NFKC-normalized, stripped of control and format characters such as zero-width spaces,
+// and its runs of spaces are collapsed. It must then be 1 to common.MaxDisplayNameLength runes long
+// and contain no word of the blocklist.
+func (u *ScoreUsecase) normalizeDisplayName(name string) (string, error) {
+	name = strings.Join(strings.Fields(stripInvisible(norm.NFKC.String(name))), " ")
+	switch n := utf8.RuneCountInString(name); {
+	case n == 0:
+		return "", &adapter.DisplayNameError{Reason: adapter.DisplayNameEmpty}
+	case n > common.MaxDisplayNameLength:
+		return "", &adapter.DisplayNameError{Reason: adapter.DisplayNameTooLong}
+	}
+	folded := foldName(name)
+	for _, word := range u.blocklist {
+		if strings.Contains(folded, word) {
+			return "", &adapter.DisplayNameError{Reason: adapter.DisplayNameBlocked}
+		}
+	}
+	return name, nil
+}
+
+// stripInvisible removes control and format characters.
+func stripInvisible(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// foldName folds a name for the blocklist so that case and spaces can't hide a word.
+func foldName(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(stripInvisible(norm.NFKC.String(s))), ""))
+}
+
+// ParseNameBlocklist parses a comma-separated list of blocked words.
+func ParseNameBlocklist(s string) []string {
+	var words []string
+	for _, word := range strings.Split(s, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
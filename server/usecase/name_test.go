@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreUsecase_normalizeDisplayName(t *testing.T) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{NameBlocklist: []string{"Bad Word", " ", "ＮＧ"}}).(*ScoreUsecase)
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		reason adapter.DisplayNameViolation
+	}{
+		{name: "ascii", input: "gopher", want: "gopher"},
+		{name: "full-width", input: "ＧＯＰＨＥＲ", want: "GOPHER"},
+		{name: "half-width katakana", input: "ｺﾞｰﾌｧｰ", want: "ゴーファー"},
+		{name: "spaces", input: "  go　　pher ", want: "go pher"},
+		{name: "zero-width and control", input: "go​ph‍\ter‮", want: "gopher"},
+		{name: "ten runes", input: "ゴーファーゴーファー", want: "ゴーファーゴーファー"},
+		{name: "eleven runes", input: "ゴーファーゴーファーX", reason: adapter.DisplayNameTooLong},
+		{name: "empty", input: "", reason: adapter.DisplayNameEmpty},
+		{name: "invisible only", input: "​ ​", reason: adapter.DisplayNameEmpty},
+		{name: "blocked", input: "xbadwordx", reason: adapter.DisplayNameBlocked},
+		{name: "blocked with case and spaces", input: "B A D word", reason: adapter.DisplayNameBlocked},
+		{name: "blocked full-width", input: "ng1", reason: adapter.DisplayNameBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := u.normalizeDisplayName(tt.input)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+				return
+			}
+			var nameErr *adapter.DisplayNameError
+			if assert.ErrorAs(t, err, &nameErr) {
+				assert.Equal(t, tt.reason, nameErr.Reason)
+			}
+			assert.ErrorIs(t, err, adapter.ErrInvalidDisplayName)
+		})
+	}
+}
+
+func TestParseNameBlocklist(t *testing.T) {
+	assert.Equal(t, []string{"a", "b c"}, ParseNameBlocklist(" a,, b c ,"))
+	assert.Empty(t, ParseNameBlocklist(""))
+}
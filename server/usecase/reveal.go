@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+const (
+	// revealAhead is the number of pipes revealed beyond the progress of a session.
+	// Checkpoints arrive every common.CheckpointInterval pipes, so the client always knows the pipes on its screen.
+	revealAhead = 2 * common.CheckpointInterval
+	// replayPipesAhead is the number of pipes of a replay beyond its score, which are on the screen when it crashes.
+	replayPipesAhead = 2
+)
+
+// RevealPipes returns the pipes revealed to a client that plays the session without its pipe key:
+// the first revealAhead pipes, and all the pipes the ghost to race against flies past, which its replay publishes anyway.
+func (u *ScoreUsecase) RevealPipes(session *common.Session, ghost *common.Score) *common.PipeSegment {
+	to := revealAhead
+	if ghost != nil {
+		to = max(to, ghost.Score+replayPipesAhead)
+	}
+	return common.CoursePipes(session.PipeKey, 0, to)
+}
+
+// validateRevealed checks that a play on a leaderboard passed only the pipes revealed to it, which are
+// the first revealAhead pipes and revealAhead beyond each checkpoint. A play past them was flown by a client
+// that knew the course in advance rather than one revealed by checkpoints, so it is rejected.
+// Ghost races are not ranked and fly a course their replay publishes anyway.
+func validateRevealed(s *common.Session, checkpoints []*common.Checkpoint, score int) error {
+	if !s.Course.IsValid() {
+		return nil
+	}
+	revealed := revealAhead
+	if n := len(checkpoints); n > 0 {
+		revealed = checkpoints[n-1].Score + revealAhead
+	}
+	if score > revealed {
+		return fmt.Errorf("%w: passed %d pipes but only %d were revealed", adapter.ErrCheckpointMismatch, score, revealed)
+	}
+	return nil
+}
+
+// publishReplay adds the pipes the replay flies past, and hides the pipe key if it is the one of today's challenge,
+// whose course is only revealed to players as they progress.
+func (u *ScoreUsecase) publishReplay(s *common.Score) (*common.Score, error) {
+	challengePipeKey, err := u.challengePipeKey(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.Pipes = common.CoursePipes(s.PipeKey, 0, s.Score+replayPipesAhead)
+	if challengePipeKey != "" && s.PipeKey == challengePipeKey {
+		s.PipeKey = ""
+	}
+	return s, nil
+}
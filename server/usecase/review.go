@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+// ListPendingScores returns the flagged scores waiting for a review, oldest first.
+// The limit falls back to DefaultPageSize and is capped at MaxPageSize.
+func (u *ScoreUsecase) ListPendingScores(limit int) ([]*common.Score, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return u.repository.ListReviewScores(common.ReviewPending, min(limit, MaxPageSize))
+}
+
+// GetReviewReplay returns the replay of a flagged score to review, whether it is listed or not.
+func (u *ScoreUsecase) GetReviewReplay(id int) (*common.Score, error) {
+	s, err := u.repository.GetScore(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Review == common.ReviewNone {
+		return nil, adapter.ErrScoreNotFlagged
+	}
+	if s.PipeKey == "" {
+		return nil, adapter.ErrReplayNotFound
+	}
+	return u.publishReplay(s)
+}
+
+// ReviewScore approves or rejects a flagged score. A review can be changed, but scores that were never
+// flagged can't be reviewed.
+func (u *ScoreUsecase) ReviewScore(id int, approved bool) error {
+	s, err := u.repository.GetScore(id)
+	if err != nil {
+		return err
+	}
+	if s.Review == common.ReviewNone {
+		return adapter.ErrScoreNotFlagged
+	}
+	review := common.ReviewRejected
+	if approved {
+		review = common.ReviewApproved
+	}
+	return u.repository.UpdateScoreReview(id, review)
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreUsecase_ReviewScore(t *testing.T) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{})
+	board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+	// register registers the play of the jump history of the pipe key of a new session.
+	register := func(name string, jumpHistory func(pipeKey string) []int) int {
+		s, _, err := u.RegisterSession(board, common.RulesVersionCurrent, 0)
+		require.NoError(t, err)
+		_, err = u.FinishSession(s.Token)
+		require.NoError(t, err)
+		id, err := u.RegisterScore(s.Token, name, "", 20, jumpHistory(s.PipeKey))
+		require.NoError(t, err)
+		return id
+	}
+	human := register("human", func(pipeKey string) []int { return humanJumpHistory(pipeKey, 3000, 1) })
+	bot := register("bot", func(pipeKey string) []int { return solverJumpHistory(pipeKey, 3000) })
+
+	scores, _, err := u.ListScore(board, common.ViewAll, "", nil, 0)
+	require.NoError(t, err)
+	require.Len(t, scores, 1)
+	assert.Equal(t, human, scores[0].ID)
+
+	pending, err := u.ListPendingScores(0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, bot, pending[0].ID)
+	assert.GreaterOrEqual(t, pending[0].Suspicion, DefaultSuspicionThreshold)
+	_, _, err = u.ListScoreAround(bot, common.ViewAll, "")
+	assert.ErrorIs(t, err, adapter.ErrScoreNotRanked)
+
+	// The replay of a pending score is hidden, also as a ghost, but can be reviewed.
+	_, err = u.GetReplay(bot)
+	assert.ErrorIs(t, err, adapter.ErrReplayNotFound)
+	_, _, err = u.RegisterSession(board, common.RulesVersionCurrent, bot)
+	assert.ErrorIs(t, err, adapter.ErrReplayNotFound)
+	replay, err := u.GetReviewReplay(bot)
+	require.NoError(t, err)
+	assert.Equal(t, bot, replay.ID)
+	_, err = u.GetReviewReplay(human)
+	assert.ErrorIs(t, err, adapter.ErrScoreNotFlagged)
+
+	assert.ErrorIs(t, u.ReviewScore(human, true), adapter.ErrScoreNotFlagged)
+	assert.ErrorIs(t, u.ReviewScore(bot+1, true), adapter.ErrScoreNotFound)
+
+	require.NoError(t, u.ReviewScore(bot, true))
+	scores, _, err = u.ListScore(board, common.ViewAll, "", nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, scores, 2)
+	pending, err = u.ListPendingScores(0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	_, err = u.GetReplay(bot)
+	assert.NoError(t, err)
+
+	// An approval can be taken back.
+	require.NoError(t, u.ReviewScore(bot, false))
+	scores, _, err = u.ListScore(board, common.ViewAll, "", nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, scores, 1)
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+)
+
+// sessionTokenTTL is how long a signed session token can be used after it is issued.
+// Spent tokens are remembered as long, since older ones are rejected anyway.
+const sessionTokenTTL = 24 * time.Hour
+
+// sessionClaims is the payload of a signed session token, which is encrypted since it holds the pipe key.
+type sessionClaims struct {
+	ID           string              `json:"id"`
+	PipeKey      string              `json:"pipeKey"`
+	Course       common.Course       `json:"course"`
+	Difficulty   common.Difficulty   `json:"difficulty"`
+	RulesVersion common.RulesVersion `json:"rulesVersion"`
+	IssuedAt     int64               `json:"issuedAt"`
+	FinishedAt   int64               `json:"finishedAt,omitempty"`
+}
+
+// signedSessions reports whether sessions live in signed tokens instead of the sessions table.
+func (u *ScoreUsecase) signedSessions() bool {
+	return u.config.SessionSecret != ""
+}
+
+// createSession stores the new session, or replaces its token with a sealed one holding the session.
+func (u *ScoreUsecase) createSession(session *common.Session) error {
+	if !u.signedSessions() {
+		return u.repository.CreateSession(session)
+	}
+	session.CreatedAt = time.Now().Truncate(time.Second)
+	token, err := u.sealSession(session.Token, session)
+	if err != nil {
+		return err
+	}
+	session.Token = token
+	return nil
+}
+
+// getSession returns the session of the token.
+// The Token of a signed session is its ID, which is what its score records.
+func (u *ScoreUsecase) getSession(token string) (*common.Session, error) {
+	if !u.signedSessions() {
+		return u.repository.GetSession(token)
+	}
+	return u.openSession(token)
+}
+
+// finishSession marks the session of the token finished now and returns the token to submit its score with.
+// A finished token is returned as it is, so that finishing again doesn't push the finish time later.
+// A signed session is finished once: its ID is recorded, so that its created token can't mint other finish times.
+func (u *ScoreUsecase) finishSession(token string) (string, error) {
+	if !u.signedSessions() {
+		return token, u.repository.UpdateSessionFinishedAt(token)
+	}
+	s, err := u.openSession(token)
+	if err != nil {
+		return "", err
+	}
+	spent, err := u.repository.IsTokenSpent(s.Token)
+	if err != nil {
+		return "", err
+	}
+	if spent {
+		return "", adapter.ErrSessionAlreadyScored
+	}
+	if s.Status == common.SessionFinished {
+		return token, nil
+	}
+	if err := u.repository.FinishToken(s.Token, s.CreatedAt.Add(sessionTokenTTL)); err != nil {
+		return "", err
+	}
+	s.FinishedAt = time.Now().Truncate(time.Second)
+	return u.sealSession(s.Token, s)
+}
+
+// claimSession marks the finished session scored so that it registers at most one score.
+func (u *ScoreUsecase) claimSession(s *common.Session, token string) error {
+	if !u.signedSessions() {
+		return u.repository.UpdateSessionScored(token)
+	}
+	if s.Status != common.SessionFinished {
+		return adapter.ErrSessionNotFinished
+	}
+	return u.repository.SpendToken(s.Token, s.CreatedAt.Add(sessionTokenTTL))
+}
+
+// sealSession encrypts the session into a token with a key derived from the SessionSecret.
+func (u *ScoreUsecase) sealSession(id string, s *common.Session) (string, error) {
+	claims := sessionClaims{
+		ID:           id,
+		PipeKey:      s.PipeKey,
+		Course:       s.Course,
+		Difficulty:   s.Difficulty,
+		RulesVersion: s.RulesVersion,
+		IssuedAt:     s.CreatedAt.Unix(),
+	}
+	if !s.FinishedAt.IsZero() {
+		claims.FinishedAt = s.FinishedAt.Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	aead, err := u.sessionAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, nil)), nil
+}
+
+// openSession decrypts the session of a token sealed by sealSession.
+// Tokens that are forged or expired are reported as ErrSessionNotFound.
+func (u *ScoreUsecase) openSession(token string) (*common.Session, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", adapter.ErrSessionNotFound)
+	}
+	aead, err := u.sessionAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: malformed token", adapter.ErrSessionNotFound)
+	}
+	payload, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid token", adapter.ErrSessionNotFound)
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	createdAt := time.Unix(claims.IssuedAt, 0)
+	if time.Since(createdAt) > sessionTokenTTL {
+		return nil, fmt.Errorf("%w: expired at %v", adapter.ErrSessionNotFound, createdAt.Add(sessionTokenTTL))
+	}
+	status, finishedAt := common.SessionCreated, time.Time{}
+	if claims.FinishedAt != 0 {
+		status, finishedAt = common.SessionFinished, time.Unix(claims.FinishedAt, 0)
+	}
+	return common.NewSession(claims.ID, claims.PipeKey, claims.Course, claims.Difficulty, claims.RulesVersion, status, finishedAt, createdAt), nil
+}
+
+// sessionAEAD returns AES-256-GCM keyed with a key derived from the SessionSecret.
+func (u *ScoreUsecase) sessionAEAD() (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(u.config.SessionSecret))
+	mac.Write([]byte("session"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
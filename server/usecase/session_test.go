@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreUsecase_signedSessions(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{SessionSecret: "secret"})
+
+	s, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	// Nothing is stored until the score is registered.
+	_, err = r.GetSession(s.Token)
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+
+	_, err = u.CalcScore(ceilingJumpHistory(), s.Token)
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFinished)
+	_, err = u.RegisterScore(s.Token, "gopher", "", 0, ceilingJumpHistory())
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFinished)
+
+	finished, err := u.FinishSession(s.Token)
+	assert.NoError(t, err)
+	assert.NotEqual(t, s.Token, finished)
+	score, err := u.CalcScore(ceilingJumpHistory(), finished)
+	assert.NoError(t, err)
+	id, err := u.RegisterScore(finished, "gopher", "", score, ceilingJumpHistory())
+	assert.NoError(t, err)
+	replay, err := u.GetReplay(id)
+	assert.NoError(t, err)
+	assert.Equal(t, s.PipeKey, replay.PipeKey)
+	assert.Len(t, replay.Token, 26)
+
+	// A scored session can't be finished again, whichever of its tokens is used.
+	for _, token := range []string{s.Token, finished} {
+		_, err = u.FinishSession(token)
+		assert.ErrorIs(t, err, adapter.ErrSessionAlreadyScored)
+	}
+
+	tampered := []byte(finished)
+	tampered[len(tampered)/2] ^= 1
+	_, err = u.CalcScore(ceilingJumpHistory(), string(tampered))
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+
+	other := NewScoreUsecase(r, Config{SessionSecret: "other"})
+	_, err = other.CalcScore(ceilingJumpHistory(), finished)
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+}
+
+func TestScoreUsecase_finishSession(t *testing.T) {
+	u := &ScoreUsecase{repository: repository.NewMemoryRepository(), config: Config{SessionSecret: "secret"}}
+	startedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	session := common.NewSession("id", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersionCurrent, common.SessionCreated, time.Time{}, startedAt)
+	token, err := u.sealSession("id", session)
+	assert.NoError(t, err)
+
+	// The pipe key is not readable from the tokens.
+	finished, err := u.finishSession(token)
+	assert.NoError(t, err)
+	for _, token := range []string{token, finished} {
+		payload, err := base64.RawURLEncoding.DecodeString(token)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(payload), "pipeKey")
+	}
+
+	// Finishing a finished token keeps its finish time.
+	again, err := u.finishSession(finished)
+	assert.NoError(t, err)
+	assert.Equal(t, finished, again)
+
+	// The created token can't be finished again, which would mint another finish time.
+	_, err = u.finishSession(token)
+	assert.ErrorIs(t, err, adapter.ErrSessionFinished)
+}
+
+func TestScoreUsecase_openSession(t *testing.T) {
+	u := &ScoreUsecase{config: Config{SessionSecret: "secret"}}
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	session := common.NewSession("id", "pipeKey", common.CourseRandom, common.DifficultyHard, common.RulesVersionCurrent, common.SessionCreated, time.Time{}, issuedAt)
+
+	token, err := u.sealSession("id", session)
+	assert.NoError(t, err)
+	got, err := u.openSession(token)
+	assert.NoError(t, err)
+	assert.Equal(t, session, got)
+
+	session.FinishedAt = issuedAt.Add(30 * time.Second)
+	token, err = u.sealSession("id", session)
+	assert.NoError(t, err)
+	got, err = u.openSession(token)
+	assert.NoError(t, err)
+	assert.Equal(t, common.SessionFinished, got.Status)
+	assert.Equal(t, session.FinishedAt, got.FinishedAt)
+
+	session.CreatedAt = time.Now().Add(-sessionTokenTTL - time.Minute)
+	token, err = u.sealSession("id", session)
+	assert.NoError(t, err)
+	_, err = u.openSession(token)
+	assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+
+	for _, token := range []string{"", "payload", "payload.signature", "c2hvcnQ"} {
+		_, err = u.openSession(token)
+		assert.ErrorIs(t, err, adapter.ErrSessionNotFound)
+	}
+}
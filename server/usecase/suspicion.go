@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"github.com/ponyo877/flappy-ranking/common"
+)
+
+// Human plays have jitter: their timing varies by a frame or two and they leave some room around the pipes.
+// Solvers tend to sit on optimal frames, which the signals of a jumpAnalysis measure.
+const (
+	// minAnalyzedPipes is the number of pipes below which a play is too short to judge.
+	minAnalyzedPipes = 10
+	// The rates that human plays reach, above which a signal starts to count, and the weights of the signals.
+	humanTightPipeRate      = 0.35
+	humanLastMomentJumpRate = 0.05
+	humanRepeatedGapRate    = 0.15
+	tightPipeWeight         = 0.5
+	lastMomentJumpWeight    = 0.3
+	repeatedGapWeight       = 0.2
+)
+
+// jumpAnalysis counts the signals of a play that tell a solver from a human.
+type jumpAnalysis struct {
+	// Pipes is the number of pipes passed, TightPipes of which as close as the gopher can fall in a frame.
+	Pipes      int
+	TightPipes int
+	// Jumps is the number of jumps, LastMomentJumps of which would have crashed if taken one frame later.
+	Jumps           int
+	LastMomentJumps int
+	// Gaps is the number of intervals between jumps, RepeatedGaps of which are as long as the previous one.
+	Gaps         int
+	RepeatedGaps int
+}
+
+// analyzeJumpHistory replays the jump history on the course of the pipe key for at most maxFrames frames,
+// as CalcScore does, and counts its signals. The jump history must be valid, as CalcScore checks.
+func analyzeJumpHistory(jumpHistory []int, pipeKey string, rules common.Rules, maxFrames int) *jumpAnalysis {
+	// Fly a copy of the course that knows its pipes in advance, so that the Object can be copied to try other jumps.
+	// The gopher can't pass more pipes than there are in maxFrames frames.
+	pipes := common.NewRevealedObject(common.InitialX16+maxFrames*rules.DeltaX16, common.InitialY16, 0, rules).Score()
+	obj := common.NewRevealedObject(common.InitialX16, common.InitialY16, 0, rules)
+	obj.AddPipes(common.CoursePipes(pipeKey, 0, pipes+replayPipesAhead))
+
+	tightClearance := rules.VyLimit / common.Unit
+	a := &jumpAnalysis{}
+	next, previousFrame, previousGap := 0, -1, -1
+	pipeClearance, inPipe := 0, false
+	for frame := 0; !obj.Hit() && frame < maxFrames; frame++ {
+		jump := next < len(jumpHistory) && jumpHistory[next] == obj.X16+rules.DeltaX16
+		if jump {
+			next++
+			a.Jumps++
+			nextX16 := -1
+			if next < len(jumpHistory) {
+				nextX16 = jumpHistory[next]
+			}
+			if crashesIfLate(*obj, nextX16) {
+				a.LastMomentJumps++
+			}
+			if previousFrame >= 0 {
+				gap := frame - previousFrame
+				a.Gaps++
+				if gap == previousGap {
+					a.RepeatedGaps++
+				}
+				previousGap = gap
+			}
+			previousFrame = frame
+		}
+		obj.Update(jump)
+
+		// A pipe is passed when the gopher leaves it without crashing.
+		clearance, ok := obj.PipeClearance()
+		switch {
+		case ok && (!inPipe || clearance < pipeClearance):
+			pipeClearance, inPipe = clearance, true
+		case !ok && inPipe && !obj.Hit():
+			inPipe = false
+			a.Pipes++
+			if pipeClearance <= tightClearance {
+				a.TightPipes++
+			}
+		}
+	}
+	return a
+}
+
+// crashesIfLate reports whether the gopher, about to jump in the next frame, would crash if it jumped
+// a frame later instead, before it reaches nextX16, its next jump, or the top of its fall if there is none.
+// The Object is a copy and must know the pipes ahead, since those it draws are not kept.
+// It flies no further than the next jump or the top of the fall, so the checks of all the jumps
+// of a play cost about as much as playing it back.
+func crashesIfLate(obj common.Object, nextX16 int) bool {
+	obj.Update(false)
+	if obj.Hit() {
+		return true
+	}
+	obj.Update(true)
+	for !obj.Hit() {
+		if nextX16 >= 0 && obj.X16+obj.Rules.DeltaX16 >= nextX16 {
+			return false
+		}
+		if nextX16 < 0 && obj.Vy16 > 0 {
+			return false
+		}
+		obj.Update(false)
+	}
+	return true
+}
+
+// suspicion combines the signals into how likely the play is to be generated by a program, from 0 to 1.
+// Each signal counts by how far its rate exceeds the rate of human plays.
+func (a *jumpAnalysis) suspicion() float64 {
+	if a.Pipes < minAnalyzedPipes {
+		return 0
+	}
+	return tightPipeWeight*excessRate(a.TightPipes, a.Pipes, humanTightPipeRate) +
+		lastMomentJumpWeight*excessRate(a.LastMomentJumps, a.Jumps, humanLastMomentJumpRate) +
+		repeatedGapWeight*excessRate(a.RepeatedGaps, a.Gaps, humanRepeatedGapRate)
+}
+
+// excessRate maps the rate of n in total from the human rate to 1 onto 0 to 1.
+func excessRate(n, total int, humanRate float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rate := float64(n) / float64(total)
+	return max(0, (rate-humanRate)/(1-humanRate))
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// solverJumpHistory jumps on the last frame that keeps the gopher out of the bottom of the next gap,
+// as a program flying the normal course would.
+func solverJumpHistory(pipeKey string, frames int) []int {
+	obj := common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey, common.DifficultyNormal.Rules())
+	var jumpHistory []int
+	for range frames {
+		late := *obj
+		late.Update(false)
+		jump := gopherBottom(&late) >= nextGapBottom(obj)-1 && obj.Vy16 >= 0
+		obj.Update(jump)
+		if jump {
+			jumpHistory = append(jumpHistory, obj.X16)
+		}
+	}
+	return jumpHistory
+}
+
+// humanJumpHistory flies the normal course like autopilotJumpHistory, but jumps with a margin
+// that varies from jump to jump, as a human would.
+func humanJumpHistory(pipeKey string, frames int, seed uint64) []int {
+	r := rand.New(rand.NewPCG(seed, 0))
+	obj := common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey, common.DifficultyNormal.Rules())
+	var jumpHistory []int
+	margin := 10 + r.IntN(14)
+	for range frames {
+		jump := gopherBottom(obj) >= nextGapBottom(obj)-margin && obj.Vy16 >= 0
+		obj.Update(jump)
+		if jump {
+			jumpHistory = append(jumpHistory, obj.X16)
+			margin = 10 + r.IntN(14)
+		}
+	}
+	return jumpHistory
+}
+
+func TestAnalyzeJumpHistory(t *testing.T) {
+	rules := common.DifficultyNormal.Rules()
+
+	solver := analyzeJumpHistory(solverJumpHistory("pipeKey", 3000), "pipeKey", rules, DefaultMaxFrames)
+	assert.GreaterOrEqual(t, solver.Pipes, minAnalyzedPipes)
+	assert.Greater(t, solver.TightPipes, solver.Pipes/2)
+	assert.Positive(t, solver.LastMomentJumps)
+	assert.GreaterOrEqual(t, solver.suspicion(), DefaultSuspicionThreshold)
+
+	for seed := range uint64(10) {
+		human := analyzeJumpHistory(humanJumpHistory("pipeKey", 3000, seed), "pipeKey", rules, DefaultMaxFrames)
+		assert.Equal(t, solver.Pipes, human.Pipes, "seed %d", seed)
+		assert.Less(t, human.suspicion(), DefaultSuspicionThreshold, "seed %d: %+v", seed, human)
+	}
+
+	// Too short to judge
+	short := analyzeJumpHistory(solverJumpHistory("pipeKey", 600), "pipeKey", rules, DefaultMaxFrames)
+	assert.Less(t, short.Pipes, minAnalyzedPipes)
+	assert.Zero(t, short.suspicion())
+	assert.Zero(t, analyzeJumpHistory(nil, "pipeKey", rules, DefaultMaxFrames).suspicion())
+
+	// The analysis stops at the frame limit, like CalcScore.
+	bounded := analyzeJumpHistory(solverJumpHistory("pipeKey", 3000), "pipeKey", rules, 600)
+	assert.Equal(t, short.Pipes, bounded.Pipes)
+}
+
+func TestExcessRate(t *testing.T) {
+	assert.Zero(t, excessRate(0, 0, 0.5))
+	assert.Zero(t, excessRate(1, 4, 0.5))
+	assert.Zero(t, excessRate(2, 4, 0.5))
+	assert.Equal(t, 0.5, excessRate(3, 4, 0.5))
+	assert.Equal(t, 1.0, excessRate(4, 4, 0.5))
+}
@@ -1,6 +1,11 @@
 package usecase
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 	_ "time/tzdata" // https://github.com/golang/go/issues/44408
@@ -9,29 +14,367 @@ import (
 	"github.com/ponyo877/flappy-ranking/server/adapter"
 )
 
+type Config struct {
+	// ChallengeSecret keys the daily challenge courses so that they can't be known in advance.
+	// Without it the daily challenge is disabled, since anyone could derive its courses.
+	ChallengeSecret string
+	// NameBlocklist lists the words that display names may not contain, whatever their case or spacing.
+	NameBlocklist []string
+	// SessionSecret signs and encrypts the session tokens if it is set. The sessions then live in the tokens
+	// instead of the sessions table, and only the IDs of the scored ones are stored until they expire.
+	SessionSecret string
+	// MaxJumps and MaxFrames bound the cost of simulating a submitted play.
+	// Zero selects DefaultMaxJumps and DefaultMaxFrames.
+	MaxJumps  int
+	MaxFrames int
+	// SuspicionThreshold is the suspicion from which scores are hidden from the boards until they are reviewed.
+	// Zero selects DefaultSuspicionThreshold.
+	SuspicionThreshold float64
+}
+
+const (
+	// DefaultMaxJumps is the longest jump history simulated if it is not configured.
+	DefaultMaxJumps = 10000
+	// DefaultMaxFrames is the longest play simulated if it is not configured, 30 minutes at 60 FPS.
+	DefaultMaxFrames = 30 * 60 * 60
+	// DefaultSuspicionThreshold flags plays that sit on optimal frames far more often than human ones do.
+	DefaultSuspicionThreshold = 0.4
+)
+
 type ScoreUsecase struct {
 	repository adapter.Repository
+	config     Config
+	// blocklist is the NameBlocklist folded by foldName.
+	blocklist []string
 }
 
-func NewScoreUsecase(repository adapter.Repository) adapter.Usecase {
-	return &ScoreUsecase{repository}
+func NewScoreUsecase(repository adapter.Repository, config Config) adapter.Usecase {
+	blocklist := make([]string, 0, len(config.NameBlocklist))
+	for _, word := range config.NameBlocklist {
+		if word = foldName(word); word != "" {
+			blocklist = append(blocklist, word)
+		}
+	}
+	if config.MaxJumps <= 0 {
+		config.MaxJumps = DefaultMaxJumps
+	}
+	if config.MaxFrames <= 0 {
+		config.MaxFrames = DefaultMaxFrames
+	}
+	if config.SuspicionThreshold <= 0 {
+		config.SuspicionThreshold = DefaultSuspicionThreshold
+	}
+	return &ScoreUsecase{repository, config, blocklist}
 }
 
-func (u *ScoreUsecase) RegisterScore(name string, score int) error {
-	return u.repository.CreateScore(name, score)
+const (
+	// minPlayerKeyLength and maxPlayerKeyLength bound the length of the keys generated by clients.
+	minPlayerKeyLength = 32
+	maxPlayerKeyLength = 128
+)
+
+// RegisterPlayer returns the player of the key, registering it on the first call.
+// A non-empty displayName that differs from the name of the player renames it.
+func (u *ScoreUsecase) RegisterPlayer(key, displayName string) (*common.Player, error) {
+	if displayName != "" {
+		name, err := u.normalizeDisplayName(displayName)
+		if err != nil {
+			return nil, err
+		}
+		displayName = name
+	}
+	player, err := u.getPlayer(key)
+	if errors.Is(err, adapter.ErrPlayerNotFound) {
+		player = common.NewPlayer(0, displayName, time.Time{})
+		createErr := u.repository.CreatePlayer(player, hashPlayerKey(key))
+		if createErr == nil {
+			return player, nil
+		}
+		// A request with the same key may have registered the player in between, which the key is unique for.
+		if player, err = u.getPlayer(key); errors.Is(err, adapter.ErrPlayerNotFound) {
+			return nil, createErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if displayName != "" && displayName != player.DisplayName {
+		if err := u.repository.UpdatePlayerName(player.ID, displayName); err != nil {
+			return nil, err
+		}
+		player.DisplayName = displayName
+	}
+	return player, nil
 }
 
-func (u *ScoreUsecase) RegisterSession(token, pipeKey string) error {
-	return u.repository.CreateSession(token, pipeKey)
+// namePlayer renames the player to the normalized displayName.
+func (u *ScoreUsecase) namePlayer(player *common.Player, displayName string) error {
+	name, err := u.normalizeDisplayName(displayName)
+	if err != nil {
+		return err
+	}
+	if err := u.repository.UpdatePlayerName(player.ID, name); err != nil {
+		return err
+	}
+	player.DisplayName = name
+	return nil
 }
 
-func (u *ScoreUsecase) ListScore(period string) ([]*common.Score, error) {
-	limit := 10
-	startTime, err := u.calcStarTime(time.Now(), period)
+// getPlayer returns the registered player of the key.
+func (u *ScoreUsecase) getPlayer(key string) (*common.Player, error) {
+	if len(key) < minPlayerKeyLength || len(key) > maxPlayerKeyLength {
+		return nil, adapter.ErrInvalidPlayerKey
+	}
+	return u.repository.GetPlayerByKey(hashPlayerKey(key))
+}
+
+// hashPlayerKey hashes the key of a player so that the database never holds the key itself.
+func hashPlayerKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// profilePeriods are the periods of the best scores on a profile, ALL_TIME being since the first play.
+var profilePeriods = []string{"DAILY", "WEEKLY", "MONTHLY", "ALL_TIME"}
+
+// recentScoreCount is the number of the latest scores on a profile.
+const recentScoreCount = 10
+
+// GetPlayerProfile returns the record of the player id on the board.
+func (u *ScoreUsecase) GetPlayerProfile(id int, board common.Board) (*common.PlayerProfile, error) {
+	if err := validateBoard(board); err != nil {
+		return nil, err
+	}
+	player, err := u.repository.GetPlayer(id)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := u.repository.GetPlayerStats(board, id)
 	if err != nil {
 		return nil, err
 	}
-	return u.repository.ListScore(startTime, limit)
+	now := time.Now()
+	bests := make(map[string]*common.Score, len(profilePeriods))
+	for _, period := range profilePeriods {
+		startTime, err := u.calcStarTime(now, period)
+		if err != nil {
+			return nil, err
+		}
+		best, err := u.repository.GetPlayerBestScore(board, id, startTime)
+		if err != nil && !errors.Is(err, adapter.ErrScoreNotFound) {
+			return nil, err
+		}
+		bests[period] = best
+	}
+	recent, err := u.repository.ListPlayerScores(board, id, recentScoreCount)
+	if err != nil {
+		return nil, err
+	}
+	return &common.PlayerProfile{
+		Player:       player,
+		Board:        board,
+		Stats:        stats,
+		Bests:        bests,
+		RecentScores: recent,
+	}, nil
+}
+
+// minOwnReplayScore is the score from which two plays of a course can't have the same jump history by chance.
+const minOwnReplayScore = 3
+
+// RegisterScore registers the score of the session and returns its ID.
+// If playerKey is not empty, the score is tied to that player and listed under its name instead of name.
+// A score whose play looks generated by a program is hidden from the boards until it is reviewed.
+func (u *ScoreUsecase) RegisterScore(token, name, playerKey string, score int, jumpHistory []int) (int, error) {
+	s, err := u.getSession(token)
+	if err != nil {
+		return 0, err
+	}
+	rules, ok := common.LookupRules(s.RulesVersion, s.Difficulty)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d %s", adapter.ErrUnsupportedRules, s.RulesVersion, s.Difficulty)
+	}
+	var player *common.Player
+	if playerKey != "" {
+		if player, err = u.getPlayer(playerKey); err != nil {
+			return 0, err
+		}
+		// A player registered without a name takes the name of its first score, as the boards list no empty names.
+		if player.DisplayName == "" {
+			if err := u.namePlayer(player, name); err != nil {
+				return 0, err
+			}
+		}
+	} else if name, err = u.normalizeDisplayName(name); err != nil {
+		return 0, err
+	}
+	// A play that copies the replay of another score of the course, such as the ghost it raced, is not its own.
+	if score >= minOwnReplayScore {
+		copied, err := u.repository.HasJumpHistory(s.PipeKey, jumpHistory)
+		if err != nil {
+			return 0, err
+		}
+		if copied {
+			return 0, adapter.ErrCopiedReplay
+		}
+	}
+	// Claim the session first so that a token registers at most one score.
+	if err := u.claimSession(s, token); err != nil {
+		return 0, err
+	}
+	submitted := common.NewSubmittedScore(name, score, s.Token, s.PipeKey, s.Course, s.Difficulty, s.RulesVersion, jumpHistory)
+	if player != nil {
+		submitted.SetPlayer(player)
+	}
+	submitted.Suspicion = analyzeJumpHistory(jumpHistory, s.PipeKey, rules, u.config.MaxFrames).suspicion()
+	if submitted.Suspicion >= u.config.SuspicionThreshold {
+		submitted.Review = common.ReviewPending
+	}
+	if err := u.repository.CreateScore(submitted); err != nil {
+		return 0, err
+	}
+	return submitted.ID, nil
+}
+
+// RegisterSession starts a session on the board, played with the rules of rulesVersion.
+// If ghostID is not 0, the session flies the course and difficulty of that replay instead,
+// which is returned as the ghost to race against.
+func (u *ScoreUsecase) RegisterSession(board common.Board, rulesVersion common.RulesVersion, ghostID int) (*common.Session, *common.Score, error) {
+	if err := validateBoard(board); err != nil {
+		return nil, nil, err
+	}
+	if !rulesVersion.IsSupported() {
+		return nil, nil, fmt.Errorf("%w: %d", adapter.ErrUnsupportedRules, rulesVersion)
+	}
+	course, difficulty := board.Course, board.Difficulty
+	challengePipeKey, err := u.challengePipeKey(time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ghost *common.Score
+	pipeKey := common.NewUlID()
+	if course == common.CourseChallenge {
+		if challengePipeKey == "" {
+			return nil, nil, adapter.ErrChallengeDisabled
+		}
+		pipeKey = challengePipeKey
+	}
+	if ghostID != 0 {
+		ghost, err = u.getReplay(ghostID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The replay of the ghost publishes the pipes it flies past, so no ghost race is ranked,
+		// not even one on today's challenge.
+		pipeKey = ghost.PipeKey
+		difficulty = ghost.Difficulty
+		course = common.CourseGhost
+	}
+
+	session := common.NewSession(common.NewUlID(), pipeKey, course, difficulty, rulesVersion, common.SessionCreated, time.Time{}, time.Time{})
+	if err := u.createSession(session); err != nil {
+		return nil, nil, err
+	}
+	if ghost != nil {
+		if ghost, err = u.publishReplay(ghost); err != nil {
+			return nil, nil, err
+		}
+	}
+	return session, ghost, nil
+}
+
+// challengePipeKey derives the pipe key of the daily challenge for the day of now in JST.
+// It is empty if the daily challenge is disabled.
+func (u *ScoreUsecase) challengePipeKey(now time.Time) (string, error) {
+	if u.config.ChallengeSecret == "" {
+		return "", nil
+	}
+	jst, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(u.config.ChallengeSecret))
+	mac.Write([]byte("challenge:" + now.In(jst).Format(time.DateOnly)))
+	return pipeKeyEncoding.EncodeToString(mac.Sum(nil))[:26], nil
+}
+
+// pipeKeyEncoding encodes derived pipe keys with the alphabet of ULIDs.
+var pipeKeyEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+const (
+	// DefaultPageSize is the size of a leaderboard page if the limit is not given.
+	DefaultPageSize = 10
+	// MaxPageSize is the largest leaderboard page served.
+	MaxPageSize = 100
+)
+
+// ListScore returns a page of the board in the period that starts after the cursor, or at the top if it is nil.
+// The limit falls back to DefaultPageSize and is capped at MaxPageSize. The returned cursor is nil on the last page.
+func (u *ScoreUsecase) ListScore(board common.Board, view common.View, period string, after *common.ScoreCursor, limit int) ([]*common.Score, *common.ScoreCursor, error) {
+	if err := validateBoard(board); err != nil {
+		return nil, nil, err
+	}
+	if !view.IsValid() {
+		return nil, nil, adapter.ErrInvalidView
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	startTime, err := u.calcStarTime(time.Now(), period)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Look one score ahead to know whether there is a next page.
+	scores, err := u.repository.ListScore(board, view, startTime, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(scores) <= limit {
+		return scores, nil, nil
+	}
+	scores = scores[:limit]
+	return scores, common.NewScoreCursor(scores[limit-1]), nil
+}
+
+func validateBoard(board common.Board) error {
+	if !board.Course.IsValid() {
+		return adapter.ErrInvalidCourse
+	}
+	if !board.Difficulty.IsValid() {
+		return adapter.ErrInvalidDifficulty
+	}
+	return nil
+}
+
+// aroundSpan is the number of places shown above and below a score by ListScoreAround.
+const aroundSpan = 5
+
+// ListScoreAround returns the scores around the entry of the score in the view and the entry itself.
+// In the best view the entry is the best score of the player, which may not be the score itself.
+func (u *ScoreUsecase) ListScoreAround(id int, view common.View, period string) ([]*common.Score, *common.Score, error) {
+	if !view.IsValid() {
+		return nil, nil, adapter.ErrInvalidView
+	}
+	s, err := u.repository.GetScore(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	startTime, err := u.calcStarTime(time.Now(), period)
+	if err != nil {
+		return nil, nil, err
+	}
+	scores, err := u.repository.ListScoreAround(common.NewBoard(s.Course, s.Difficulty), view, startTime, s, aroundSpan)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, score := range scores {
+		if score.ID == id || (view == common.ViewBest && score.PlayerKey == s.PlayerKey) {
+			return scores, score, nil
+		}
+	}
+	return nil, nil, adapter.ErrScoreNotRanked
 }
 
 func (u *ScoreUsecase) calcStarTime(now time.Time, period string) (time.Time, error) {
@@ -54,42 +397,98 @@ func (u *ScoreUsecase) calcStarTime(now time.Time, period string) (time.Time, er
 }
 
 func (u *ScoreUsecase) CalcScore(jumpHistory []int, token string) (int, error) {
-	s, err := u.repository.GetSession(token)
+	if len(jumpHistory) > u.config.MaxJumps {
+		return 0, fmt.Errorf("%w: %d > %d", adapter.ErrTooManyJumps, len(jumpHistory), u.config.MaxJumps)
+	}
+	s, err := u.getSession(token)
+	if err != nil {
+		return 0, err
+	}
+	switch s.Status {
+	case common.SessionCreated:
+		return 0, adapter.ErrSessionNotFinished
+	case common.SessionScored:
+		return 0, adapter.ErrSessionAlreadyScored
+	}
+	// Simulate with the rules the session was played with, even if they are not the current ones.
+	rules, ok := common.LookupRules(s.RulesVersion, s.Difficulty)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d %s", adapter.ErrUnsupportedRules, s.RulesVersion, s.Difficulty)
+	}
+	if err := validateJumpHistory(jumpHistory, rules); err != nil {
+		return 0, err
+	}
+	obj, err := u.simulateObject(jumpHistory, s.PipeKey, rules)
 	if err != nil {
 		return 0, err
 	}
-	obj := u.simulateObject(jumpHistory, s.PipeKey)
+	// Entries past the crash were never played back.
+	if i := len(jumpHistory) - 1; i >= 0 && jumpHistory[i] > obj.X16 {
+		for i > 0 && jumpHistory[i-1] > obj.X16 {
+			i--
+		}
+		return 0, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryAfterCrash, Index: i}
+	}
 
 	// Validate Play Time
 	if !obj.IsValidTimeDiff(s.CreatedAt, s.FinishedAt) {
 		return 0, fmt.Errorf("invalid end time: startTime=%v, endTime=%v", s.CreatedAt, s.FinishedAt)
 	}
+	checkpoints, err := u.repository.ListCheckpoints(s.Token)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateCheckpoints(s, checkpoints, obj.X16/rules.DeltaX16, rules); err != nil {
+		return 0, err
+	}
+	if err := validateRevealed(s, checkpoints, obj.Score()); err != nil {
+		return 0, err
+	}
 	return obj.Score(), nil
 }
 
-func (u *ScoreUsecase) simulateObject(jumpHistory []int, pipeKey string) *common.Object {
-	obj := common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey)
-
-	// Sentinel
-	jumpHistory = append(jumpHistory, -1)
-	i := 0
-	for !obj.Hit() {
-		obj.X16 += common.DeltaX16
-		if jumpHistory[i] == obj.X16 {
-			i++
-			obj.Vy16 = -common.VyLimit
+// simulateObject plays the jump history back until the gopher crashes, or fails with ErrTooManyFrames
+// if it is still flying after MaxFrames frames.
+func (u *ScoreUsecase) simulateObject(jumpHistory []int, pipeKey string, rules common.Rules) (*common.Object, error) {
+	// A history that jumps after the limit can't end within it.
+	if n := len(jumpHistory); n > 0 && jumpHistory[n-1]/rules.DeltaX16 > u.config.MaxFrames {
+		return nil, fmt.Errorf("%w: jump at frame %d", adapter.ErrTooManyFrames, jumpHistory[n-1]/rules.DeltaX16)
+	}
+	obj := common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey, rules)
+	r := common.NewReplayer(obj, jumpHistory)
+	for frame := 0; !obj.Hit(); frame++ {
+		if frame == u.config.MaxFrames {
+			return nil, fmt.Errorf("%w: still flying after %d frames", adapter.ErrTooManyFrames, frame)
 		}
-		obj.Y16 += obj.Vy16
+		r.Update()
+	}
+	return obj, nil
+}
 
-		// Gravity
-		obj.Vy16 += common.DeltaVy16
-		if obj.Vy16 > common.VyLimit {
-			obj.Vy16 = common.VyLimit
-		}
+func (u *ScoreUsecase) FinishSession(token string) (string, error) {
+	return u.finishSession(token)
+}
+
+func (u *ScoreUsecase) GetReplay(id int) (*common.Score, error) {
+	s, err := u.getReplay(id)
+	if err != nil {
+		return nil, err
 	}
-	return obj
+	return u.publishReplay(s)
 }
 
-func (u *ScoreUsecase) FinishSession(token string) error {
-	return u.repository.UpdateSessionFinishedAt(token)
+func (u *ScoreUsecase) getReplay(id int) (*common.Score, error) {
+	s, err := u.repository.GetScore(id)
+	if err != nil {
+		return nil, err
+	}
+	// Scores registered before replays were stored have no pipe key.
+	if s.PipeKey == "" {
+		return nil, adapter.ErrReplayNotFound
+	}
+	// Flagged scores are hidden until they are approved, and can't be raced as ghosts either.
+	if !s.Review.IsListed() {
+		return nil, adapter.ErrReplayNotFound
+	}
+	return s, nil
 }
@@ -1,14 +1,21 @@
 package usecase
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ponyo877/flappy-ranking/common"
+	"github.com/ponyo877/flappy-ranking/server/adapter"
+	"github.com/ponyo877/flappy-ranking/server/repository"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestScoreUsecase_simulateObject(t *testing.T) {
-	u := &ScoreUsecase{}
+	u := &ScoreUsecase{config: Config{MaxFrames: DefaultMaxFrames}}
 
 	type args struct {
 		jumpHistory []int
@@ -30,7 +37,8 @@ func TestScoreUsecase_simulateObject(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := u.simulateObject(tt.args.jumpHistory, tt.args.pipeKey)
+			got, err := u.simulateObject(tt.args.jumpHistory, tt.args.pipeKey, common.DifficultyNormal.Rules())
+			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got.Score())
 		})
 	}
@@ -89,3 +97,440 @@ func TestScoreUsecase_calcStarTime(t *testing.T) {
 		})
 	}
 }
+
+// ceilingJumpHistory jumps on every frame until the gopher hits the ceiling,
+// which ends the game within a second.
+func ceilingJumpHistory() []int {
+	var jumpHistory []int
+	deltaX16 := common.DifficultyNormal.Rules().DeltaX16
+	for x16 := deltaX16; x16 <= 40*deltaX16; x16 += deltaX16 {
+		jumpHistory = append(jumpHistory, x16)
+	}
+	return jumpHistory
+}
+
+// autopilotJumpHistory jumps whenever the gopher is about to fall out of the next gap,
+// which flies the normal course for as many frames as asked.
+func autopilotJumpHistory(pipeKey string, frames int) []int {
+	rules := common.DifficultyNormal.Rules()
+	obj := common.NewObject(common.InitialX16, common.InitialY16, 0, pipeKey, rules)
+	var jumpHistory []int
+	for range frames {
+		jump := gopherBottom(obj) >= nextGapBottom(obj)-12 && obj.Vy16 >= 0
+		obj.Update(jump)
+		if jump {
+			jumpHistory = append(jumpHistory, obj.X16)
+		}
+	}
+	return jumpHistory
+}
+
+// gopherBottom returns the bottom of the hit box of the gopher.
+func gopherBottom(obj *common.Object) int {
+	return obj.Y16/common.Unit + (75-obj.Rules.GopherHeight)/2 + obj.Rules.GopherHeight
+}
+
+// nextGapBottom returns the bottom of the gap of the pipe the gopher is in or flies to next.
+func nextGapBottom(obj *common.Object) int {
+	x0 := obj.X16/common.Unit + (60-obj.Rules.GopherWidth)/2
+	for x := common.FloorDiv(x0-common.PipeWidth, common.TileSize); ; x++ {
+		if y, ok := obj.PipeAt(x); ok && x*common.TileSize+common.PipeWidth > x0 {
+			return (y + obj.Rules.PipeGapY) * common.TileSize
+		}
+	}
+}
+
+func TestScoreUsecase_CalcScore(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	s, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	_, err = u.FinishSession(s.Token)
+	assert.NoError(t, err)
+
+	// A session of rules that this server no longer simulates
+	assert.NoError(t, r.CreateSession(common.NewSession("retired", "pipeKey", common.CourseRandom, common.DifficultyNormal, 99, common.SessionCreated, time.Time{}, time.Time{})))
+	_, err = u.FinishSession("retired")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		token       string
+		jumpHistory []int
+		want        int
+		wantErr     error
+	}{
+		{
+			name:        "crash into the ceiling",
+			token:       s.Token,
+			jumpHistory: ceilingJumpHistory(),
+			want:        0,
+		},
+		{
+			name:        "jump after the crash",
+			token:       s.Token,
+			jumpHistory: append(ceilingJumpHistory(), 1000*common.DifficultyNormal.Rules().DeltaX16),
+			wantErr:     adapter.ErrInvalidJumpHistory,
+		},
+		{
+			name:        "unsupported rules version",
+			token:       "retired",
+			jumpHistory: ceilingJumpHistory(),
+			wantErr:     adapter.ErrUnsupportedRules,
+		},
+		{
+			name:        "unknown token",
+			token:       "unknown",
+			jumpHistory: []int{},
+			wantErr:     adapter.ErrSessionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := u.CalcScore(tt.jumpHistory, tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// The gopher crashes into the ceiling long before the added jumps, so the first of them is reported.
+	jumpHistory := ceilingJumpHistory()
+	deltaX16 := common.DifficultyNormal.Rules().DeltaX16
+	_, err = u.CalcScore(append(jumpHistory, 1000*deltaX16, 1001*deltaX16), s.Token)
+	assert.Equal(t, &adapter.JumpHistoryError{Reason: adapter.JumpHistoryAfterCrash, Index: len(jumpHistory)}, err)
+}
+
+func TestScoreUsecase_CalcScore_limits(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{MaxJumps: 100, MaxFrames: 600})
+	s, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	_, err = u.FinishSession(s.Token)
+	assert.NoError(t, err)
+
+	_, err = u.CalcScore(ceilingJumpHistory(), s.Token)
+	assert.NoError(t, err)
+
+	// The autopilot is still flying at the limit.
+	_, err = u.CalcScore(autopilotJumpHistory(s.PipeKey, 600), s.Token)
+	assert.ErrorIs(t, err, adapter.ErrTooManyFrames)
+	// A jump after the limit is rejected before simulating.
+	deltaX16 := common.DifficultyNormal.Rules().DeltaX16
+	_, err = u.CalcScore([]int{601 * deltaX16}, s.Token)
+	assert.ErrorIs(t, err, adapter.ErrTooManyFrames)
+
+	_, err = u.CalcScore(make([]int, 101), s.Token)
+	assert.ErrorIs(t, err, adapter.ErrTooManyJumps)
+}
+
+// BenchmarkScoreUsecase_CalcScore measures the cost of a submission, the worst case being
+// a history that flies until the frame limit.
+func BenchmarkScoreUsecase_CalcScore(b *testing.B) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{})
+	s, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := u.FinishSession(s.Token); err != nil {
+		b.Fatal(err)
+	}
+	benchmarks := []struct {
+		name        string
+		jumpHistory []int
+	}{
+		{"ceiling crash", ceilingJumpHistory()},
+		{"max frames", autopilotJumpHistory(s.PipeKey, DefaultMaxFrames)},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for range b.N {
+				// The play time doesn't match the session, so only the cost is of interest.
+				_, _ = u.CalcScore(bm.jumpHistory, s.Token)
+			}
+		})
+	}
+}
+
+func BenchmarkScoreUsecase_RegisterScore(b *testing.B) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{SessionSecret: "secret"}).(*ScoreUsecase)
+	// Every session flies the same course, on which the play lasts as long as it can and is analyzed throughout.
+	jumpHistory := autopilotJumpHistory("pipeKey", DefaultMaxFrames)
+	for i := range b.N {
+		b.StopTimer()
+		id := strconv.Itoa(i)
+		session := common.NewSession(id, "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersionCurrent, common.SessionFinished, time.Now(), time.Now())
+		token, err := u.sealSession(id, session)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		// A score of 0 skips the check for copied replays, whose cost is the database's.
+		if _, err := u.RegisterScore(token, "gopher", "", 0, jumpHistory); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestScoreUsecase_challengePipeKey(t *testing.T) {
+	jst, _ := time.LoadLocation("Asia/Tokyo")
+	u := &ScoreUsecase{config: Config{ChallengeSecret: "secret"}}
+
+	morning, _ := u.challengePipeKey(time.Date(2024, 11, 16, 0, 0, 0, 0, jst))
+	night, _ := u.challengePipeKey(time.Date(2024, 11, 16, 23, 59, 59, 0, jst))
+	nextDay, _ := u.challengePipeKey(time.Date(2024, 11, 17, 0, 0, 0, 0, jst))
+	assert.Len(t, morning, 26)
+	assert.Equal(t, morning, night)
+	assert.NotEqual(t, morning, nextDay)
+
+	other := &ScoreUsecase{config: Config{ChallengeSecret: "other"}}
+	otherMorning, _ := other.challengePipeKey(time.Date(2024, 11, 16, 0, 0, 0, 0, jst))
+	assert.NotEqual(t, morning, otherMorning)
+
+	// Without a secret anyone could derive the courses, so there are none.
+	disabled, err := (&ScoreUsecase{}).challengePipeKey(time.Date(2024, 11, 16, 0, 0, 0, 0, jst))
+	assert.NoError(t, err)
+	assert.Empty(t, disabled)
+}
+
+func TestScoreUsecase_RegisterSession_challengeDisabled(t *testing.T) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{})
+	_, _, err := u.RegisterSession(common.NewBoard(common.CourseChallenge, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.ErrorIs(t, err, adapter.ErrChallengeDisabled)
+	_, _, err = u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+}
+
+func TestScoreUsecase_RegisterSession(t *testing.T) {
+	u := NewScoreUsecase(repository.NewMemoryRepository(), Config{ChallengeSecret: "secret"})
+
+	random1, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	random2, _, err := u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, random1.PipeKey, random2.PipeKey)
+
+	challenge1, _, err := u.RegisterSession(common.NewBoard(common.CourseChallenge, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	challenge2, _, err := u.RegisterSession(common.NewBoard(common.CourseChallenge, common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, challenge1.Token, challenge2.Token)
+	assert.Equal(t, challenge1.PipeKey, challenge2.PipeKey)
+	assert.Equal(t, common.CourseChallenge, challenge1.Course)
+
+	assert.Equal(t, common.DifficultyNormal, challenge1.Difficulty)
+
+	hard, _, err := u.RegisterSession(common.NewBoard(common.CourseChallenge, common.DifficultyHard), common.RulesVersionCurrent, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, challenge1.PipeKey, hard.PipeKey)
+	assert.Equal(t, common.DifficultyHard, hard.Difficulty)
+
+	_, _, err = u.RegisterSession(common.NewBoard("UNKNOWN", common.DifficultyNormal), common.RulesVersionCurrent, 0)
+	assert.ErrorIs(t, err, adapter.ErrInvalidCourse)
+	_, _, err = u.RegisterSession(common.NewBoard(common.CourseRandom, "UNKNOWN"), common.RulesVersionCurrent, 0)
+	assert.ErrorIs(t, err, adapter.ErrInvalidDifficulty)
+	_, _, err = u.RegisterSession(common.NewBoard(common.CourseRandom, common.DifficultyNormal), 99, 0)
+	assert.ErrorIs(t, err, adapter.ErrUnsupportedRules)
+}
+
+func TestScoreUsecase_RegisterPlayer(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	key := strings.Repeat("k", minPlayerKeyLength)
+
+	player, err := u.RegisterPlayer(key, "gopher")
+	assert.NoError(t, err)
+	assert.NotZero(t, player.ID)
+	assert.Equal(t, "gopher", player.DisplayName)
+
+	// Signing in without a name keeps it.
+	again, err := u.RegisterPlayer(key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, player.ID, again.ID)
+	assert.Equal(t, "gopher", again.DisplayName)
+
+	other, err := u.RegisterPlayer(strings.Repeat("o", minPlayerKeyLength), "gopher")
+	assert.NoError(t, err)
+	assert.NotEqual(t, player.ID, other.ID)
+
+	for _, key := range []string{"", strings.Repeat("k", minPlayerKeyLength-1), strings.Repeat("k", maxPlayerKeyLength+1)} {
+		_, err = u.RegisterPlayer(key, "gopher")
+		assert.ErrorIs(t, err, adapter.ErrInvalidPlayerKey)
+	}
+}
+
+// racingRepository registers the player of the first key it is asked for just before answering that there is none,
+// as a concurrent first request with the same key would.
+type racingRepository struct {
+	adapter.Repository
+	raced bool
+}
+
+func (r *racingRepository) GetPlayerByKey(keyHash string) (*common.Player, error) {
+	if !r.raced {
+		r.raced = true
+		if err := r.Repository.CreatePlayer(common.NewPlayer(0, "racer", time.Time{}), keyHash); err != nil {
+			return nil, err
+		}
+		return nil, adapter.ErrPlayerNotFound
+	}
+	return r.Repository.GetPlayerByKey(keyHash)
+}
+
+func TestScoreUsecase_RegisterPlayer_concurrent(t *testing.T) {
+	r := &racingRepository{Repository: repository.NewMemoryRepository()}
+	u := NewScoreUsecase(r, Config{})
+
+	// The player registered in between is returned, and renamed like any other.
+	player, err := u.RegisterPlayer(strings.Repeat("k", minPlayerKeyLength), "gopher")
+	require.NoError(t, err)
+	assert.Equal(t, 1, player.ID)
+	assert.Equal(t, "gopher", player.DisplayName)
+	registered, err := r.GetPlayer(1)
+	require.NoError(t, err)
+	assert.Equal(t, "gopher", registered.DisplayName)
+}
+
+func TestScoreUsecase_RegisterScore_namelessPlayer(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+	key := strings.Repeat("k", minPlayerKeyLength)
+	player, err := u.RegisterPlayer(key, "")
+	require.NoError(t, err)
+	assert.Empty(t, player.DisplayName)
+	s, _, err := u.RegisterSession(board, common.RulesVersionCurrent, 0)
+	require.NoError(t, err)
+	_, err = u.FinishSession(s.Token)
+	require.NoError(t, err)
+
+	// The score of a nameless player is listed under the name it is submitted with, which must be valid.
+	_, err = u.RegisterScore(s.Token, " ", key, 0, ceilingJumpHistory())
+	var nameErr *adapter.DisplayNameError
+	if assert.ErrorAs(t, err, &nameErr) {
+		assert.Equal(t, adapter.DisplayNameEmpty, nameErr.Reason)
+	}
+	_, err = u.RegisterScore(s.Token, " gopher ", key, 0, ceilingJumpHistory())
+	require.NoError(t, err)
+
+	scores, _, err := u.ListScore(board, common.ViewAll, "", nil, 0)
+	require.NoError(t, err)
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, "gopher", scores[0].DisplayName)
+	}
+	named, err := r.GetPlayer(player.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "gopher", named.DisplayName)
+}
+
+func TestScoreUsecase_GetPlayerProfile(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+	player, err := u.RegisterPlayer(strings.Repeat("k", minPlayerKeyLength), "gopher")
+	assert.NoError(t, err)
+	for i := range recentScoreCount + 2 {
+		score := common.NewSubmittedScore("gopher", i, fmt.Sprintf("token-%d", i), "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+		score.SetPlayer(player)
+		assert.NoError(t, r.CreateScore(score))
+	}
+
+	profile, err := u.GetPlayerProfile(player.ID, board)
+	assert.NoError(t, err)
+	assert.Equal(t, "gopher", profile.Player.DisplayName)
+	assert.Equal(t, recentScoreCount+2, profile.Stats.Plays)
+	assert.InDelta(t, float64(recentScoreCount+1)/2, profile.Stats.AverageScore, 1e-9)
+	for _, period := range profilePeriods {
+		if assert.NotNil(t, profile.Bests[period], period) {
+			assert.Equal(t, recentScoreCount+1, profile.Bests[period].Score)
+		}
+	}
+	if assert.Len(t, profile.RecentScores, recentScoreCount) {
+		assert.Equal(t, recentScoreCount+1, profile.RecentScores[0].Score)
+	}
+
+	// No plays on the other board
+	profile, err = u.GetPlayerProfile(player.ID, common.NewBoard(common.CourseRandom, common.DifficultyHard))
+	assert.NoError(t, err)
+	assert.Zero(t, profile.Stats.Plays)
+	assert.Nil(t, profile.Bests["ALL_TIME"])
+	assert.Empty(t, profile.RecentScores)
+
+	_, err = u.GetPlayerProfile(player.ID+1, board)
+	assert.ErrorIs(t, err, adapter.ErrPlayerNotFound)
+	_, err = u.GetPlayerProfile(player.ID, common.NewBoard(common.CourseRandom, "UNKNOWN"))
+	assert.ErrorIs(t, err, adapter.ErrInvalidDifficulty)
+}
+
+func TestScoreUsecase_ListScore(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	board := common.NewBoard(common.CourseRandom, common.DifficultyNormal)
+	for i := range MaxPageSize + 1 {
+		assert.NoError(t, r.CreateScore(common.NewSubmittedScore("gopher", i, fmt.Sprintf("token-%d", i), "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)))
+	}
+
+	scores, next, err := u.ListScore(board, common.ViewAll, "", nil, 0)
+	assert.NoError(t, err)
+	assert.Len(t, scores, DefaultPageSize)
+	assert.NotNil(t, next)
+
+	scores, next, err = u.ListScore(board, common.ViewAll, "", nil, MaxPageSize+1)
+	assert.NoError(t, err)
+	assert.Len(t, scores, MaxPageSize)
+	if assert.NotNil(t, next) {
+		assert.Equal(t, common.NewScoreCursor(scores[MaxPageSize-1]), next)
+	}
+
+	scores, next, err = u.ListScore(board, common.ViewAll, "", next, MaxPageSize)
+	assert.NoError(t, err)
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, 0, scores[0].Score)
+		assert.Equal(t, MaxPageSize+1, scores[0].Rank)
+	}
+	assert.Nil(t, next)
+
+	// Every score is of the same player.
+	scores, next, err = u.ListScore(board, common.ViewBest, "", nil, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, MaxPageSize, scores[0].Score)
+	}
+	assert.Nil(t, next)
+
+	_, _, err = u.ListScore(common.NewBoard("UNKNOWN", common.DifficultyNormal), common.ViewAll, "", nil, 0)
+	assert.ErrorIs(t, err, adapter.ErrInvalidCourse)
+	_, _, err = u.ListScore(board, "UNKNOWN", "", nil, 0)
+	assert.ErrorIs(t, err, adapter.ErrInvalidView)
+}
+
+func TestScoreUsecase_ListScoreAround(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	u := NewScoreUsecase(r, Config{})
+	best := common.NewSubmittedScore("gopher", 9, "token-1", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+	worse := common.NewSubmittedScore("gopher", 3, "token-2", "pipeKey", common.CourseRandom, common.DifficultyNormal, common.RulesVersion1, nil)
+	assert.NoError(t, r.CreateScore(best))
+	assert.NoError(t, r.CreateScore(worse))
+
+	scores, entry, err := u.ListScoreAround(worse.ID, common.ViewAll, "")
+	assert.NoError(t, err)
+	assert.Len(t, scores, 2)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, worse.ID, entry.ID)
+		assert.Equal(t, 2, entry.Rank)
+	}
+
+	scores, entry, err = u.ListScoreAround(worse.ID, common.ViewBest, "")
+	assert.NoError(t, err)
+	assert.Len(t, scores, 1)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, best.ID, entry.ID)
+		assert.Equal(t, 1, entry.Rank)
+	}
+
+	_, _, err = u.ListScoreAround(worse.ID+1, common.ViewBest, "")
+	assert.ErrorIs(t, err, adapter.ErrScoreNotFound)
+}
@@ -1,13 +1,13 @@
-CREATE TABLE scores (
+CREATE TABLE IF NOT EXISTS scores (
     id           INTEGER  PRIMARY KEY AUTOINCREMENT,
     display_name TEXT(10) NOT NULL,
     score        INTEGER  NOT NULL,
     created_at   INTEGER  NOT NULL
 );
 
-CREATE INDEX idx_created_at_score ON scores (created_at, score DESC);
+CREATE INDEX IF NOT EXISTS idx_created_at_score ON scores (created_at, score DESC);
 
-CREATE TABLE sessions (
+CREATE TABLE IF NOT EXISTS sessions (
     id          INTEGER  PRIMARY KEY AUTOINCREMENT,
     token       TEXT(26) NOT NULL,
     pipe_key    TEXT(26) NOT NULL,
@@ -15,4 +15,4 @@ CREATE TABLE sessions (
     created_at  INTEGER  NOT NULL
 );
 
-CREATE INDEX idx_token ON sessions (token);
+CREATE INDEX IF NOT EXISTS idx_token ON sessions (token);
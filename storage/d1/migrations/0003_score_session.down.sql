@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_scores_token;
+
+ALTER TABLE scores DROP COLUMN jump_history;
+ALTER TABLE scores DROP COLUMN pipe_key;
+ALTER TABLE scores DROP COLUMN token;
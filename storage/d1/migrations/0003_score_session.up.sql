@@ -0,0 +1,5 @@
+ALTER TABLE scores ADD COLUMN token TEXT(26);
+ALTER TABLE scores ADD COLUMN pipe_key TEXT(26);
+ALTER TABLE scores ADD COLUMN jump_history TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scores_token ON scores (token);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_course_created_at_score;
+
+ALTER TABLE scores DROP COLUMN course;
+ALTER TABLE sessions DROP COLUMN course;
@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN course TEXT(16) NOT NULL DEFAULT 'RANDOM';
+ALTER TABLE scores ADD COLUMN course TEXT(16) NOT NULL DEFAULT 'RANDOM';
+
+CREATE INDEX IF NOT EXISTS idx_course_created_at_score ON scores (course, created_at, score DESC);
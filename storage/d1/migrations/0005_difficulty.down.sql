@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_board_created_at_score;
+CREATE INDEX IF NOT EXISTS idx_course_created_at_score ON scores (course, created_at, score DESC);
+
+ALTER TABLE scores DROP COLUMN difficulty;
+ALTER TABLE sessions DROP COLUMN difficulty;
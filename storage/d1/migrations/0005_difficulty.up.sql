@@ -0,0 +1,5 @@
+ALTER TABLE sessions ADD COLUMN difficulty TEXT(16) NOT NULL DEFAULT 'NORMAL';
+ALTER TABLE scores ADD COLUMN difficulty TEXT(16) NOT NULL DEFAULT 'NORMAL';
+
+DROP INDEX IF EXISTS idx_course_created_at_score;
+CREATE INDEX IF NOT EXISTS idx_board_created_at_score ON scores (course, difficulty, created_at, score DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE scores DROP COLUMN rules_version;
+ALTER TABLE sessions DROP COLUMN rules_version;
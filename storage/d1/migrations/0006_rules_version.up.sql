@@ -0,0 +1,2 @@
+ALTER TABLE sessions ADD COLUMN rules_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE scores ADD COLUMN rules_version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE scores DROP COLUMN player_key;
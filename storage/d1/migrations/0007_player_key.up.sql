@@ -0,0 +1,3 @@
+ALTER TABLE scores ADD COLUMN player_key TEXT(64) NOT NULL DEFAULT '';
+
+UPDATE scores SET player_key = 'name:' || display_name;
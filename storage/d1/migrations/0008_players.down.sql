@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_player_id_created_at;
+
+ALTER TABLE scores DROP COLUMN player_id;
+
+DROP TABLE IF EXISTS players;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS players (
+    id           INTEGER  PRIMARY KEY AUTOINCREMENT,
+    key_hash     TEXT(64) NOT NULL,
+    display_name TEXT(10) NOT NULL,
+    created_at   INTEGER  NOT NULL,
+    updated_at   INTEGER  NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_players_key_hash ON players (key_hash);
+
+ALTER TABLE scores ADD COLUMN player_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_player_id_created_at ON scores (player_id, created_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS spent_tokens (
+    id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+    token_id   TEXT(26) NOT NULL,
+    expires_at INTEGER  NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_spent_tokens_token_id ON spent_tokens (token_id);
+
+CREATE INDEX IF NOT EXISTS idx_spent_tokens_expires_at ON spent_tokens (expires_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+    token      TEXT(26) NOT NULL,
+    score      INTEGER  NOT NULL,
+    arrived_at INTEGER  NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkpoints_token_score ON checkpoints (token, score);
+
+CREATE INDEX IF NOT EXISTS idx_checkpoints_arrived_at ON checkpoints (arrived_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_review_created_at;
+
+ALTER TABLE scores DROP COLUMN review;
+ALTER TABLE scores DROP COLUMN suspicion;
@@ -0,0 +1,4 @@
+ALTER TABLE scores ADD COLUMN suspicion REAL NOT NULL DEFAULT 0;
+ALTER TABLE scores ADD COLUMN review TEXT(16) NOT NULL DEFAULT 'NONE';
+
+CREATE INDEX IF NOT EXISTS idx_review_created_at ON scores (review, created_at);
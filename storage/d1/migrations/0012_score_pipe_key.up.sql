@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_pipe_key ON scores (pipe_key);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS finished_tokens (
+    id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+    token_id   TEXT(26) NOT NULL,
+    expires_at INTEGER  NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_finished_tokens_token_id ON finished_tokens (token_id);
+
+CREATE INDEX IF NOT EXISTS idx_finished_tokens_expires_at ON finished_tokens (expires_at);
@@ -1,2 +0,0 @@
-DROP TABLE scores;
-DROP TABLE sessions;
@@ -1,4 +1,4 @@
-CREATE TABLE flappy.scores (
+CREATE TABLE IF NOT EXISTS scores (
     id           INT         AUTO_INCREMENT PRIMARY KEY,
     display_name VARCHAR(10) NOT NULL,
     score        INT         NOT NULL,
@@ -6,7 +6,7 @@ CREATE TABLE flappy.scores (
     INDEX idx_created_at_acore (created_at, score DESC)
 );
 
-CREATE TABLE flappy.sessions (
+CREATE TABLE IF NOT EXISTS sessions (
     id          INT         AUTO_INCREMENT PRIMARY KEY,
     token       VARCHAR(26) NOT NULL,
     pipe_key    VARCHAR(26) NOT NULL,
@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN status VARCHAR(8) NOT NULL DEFAULT 'created';
+
+-- Sessions created before this migration can no longer register scores.
+UPDATE sessions SET status = 'scored';
@@ -0,0 +1,5 @@
+ALTER TABLE scores
+    DROP INDEX idx_scores_token,
+    DROP COLUMN jump_history,
+    DROP COLUMN pipe_key,
+    DROP COLUMN token;
@@ -0,0 +1,5 @@
+ALTER TABLE scores
+    ADD COLUMN token        VARCHAR(26),
+    ADD COLUMN pipe_key     VARCHAR(26),
+    ADD COLUMN jump_history TEXT,
+    ADD UNIQUE INDEX idx_scores_token (token);
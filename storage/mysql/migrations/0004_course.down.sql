@@ -0,0 +1,5 @@
+ALTER TABLE scores
+    DROP INDEX idx_course_created_at_score,
+    DROP COLUMN course;
+
+ALTER TABLE sessions DROP COLUMN course;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions ADD COLUMN course VARCHAR(16) NOT NULL DEFAULT 'RANDOM';
+
+ALTER TABLE scores
+    ADD COLUMN course VARCHAR(16) NOT NULL DEFAULT 'RANDOM',
+    ADD INDEX idx_course_created_at_score (course, created_at, score DESC);
@@ -0,0 +1,6 @@
+ALTER TABLE scores
+    DROP INDEX idx_board_created_at_score,
+    ADD INDEX idx_course_created_at_score (course, created_at, score DESC),
+    DROP COLUMN difficulty;
+
+ALTER TABLE sessions DROP COLUMN difficulty;
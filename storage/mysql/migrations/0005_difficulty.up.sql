@@ -0,0 +1,6 @@
+ALTER TABLE sessions ADD COLUMN difficulty VARCHAR(16) NOT NULL DEFAULT 'NORMAL';
+
+ALTER TABLE scores
+    ADD COLUMN difficulty VARCHAR(16) NOT NULL DEFAULT 'NORMAL',
+    DROP INDEX idx_course_created_at_score,
+    ADD INDEX idx_board_created_at_score (course, difficulty, created_at, score DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE scores ADD COLUMN player_key VARCHAR(64) NOT NULL DEFAULT '';
+
+UPDATE scores SET player_key = CONCAT('name:', display_name);
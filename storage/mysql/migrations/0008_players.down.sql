@@ -0,0 +1,5 @@
+ALTER TABLE scores
+    DROP INDEX idx_player_id_created_at,
+    DROP COLUMN player_id;
+
+DROP TABLE IF EXISTS players;
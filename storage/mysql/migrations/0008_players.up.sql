@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS players (
+    id           INT         AUTO_INCREMENT PRIMARY KEY,
+    key_hash     VARCHAR(64) NOT NULL,
+    display_name VARCHAR(10) NOT NULL,
+    created_at   TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_players_key_hash (key_hash)
+);
+
+ALTER TABLE scores
+    ADD COLUMN player_id INT,
+    ADD INDEX idx_player_id_created_at (player_id, created_at);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id         INT         AUTO_INCREMENT PRIMARY KEY,
+    token      VARCHAR(26) NOT NULL,
+    score      INT         NOT NULL,
+    arrived_at BIGINT      NOT NULL,
+    UNIQUE INDEX idx_checkpoints_token_score (token, score),
+    INDEX idx_checkpoints_arrived_at (arrived_at)
+);
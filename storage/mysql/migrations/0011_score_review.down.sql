@@ -0,0 +1,4 @@
+ALTER TABLE scores
+    DROP INDEX idx_review_created_at,
+    DROP COLUMN review,
+    DROP COLUMN suspicion;
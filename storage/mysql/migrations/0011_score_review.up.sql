@@ -0,0 +1,4 @@
+ALTER TABLE scores
+    ADD COLUMN suspicion DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN review VARCHAR(16) NOT NULL DEFAULT 'NONE',
+    ADD INDEX idx_review_created_at (review, created_at);
@@ -0,0 +1 @@
+ALTER TABLE scores DROP INDEX idx_pipe_key;
@@ -0,0 +1 @@
+ALTER TABLE scores ADD INDEX idx_pipe_key (pipe_key);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS finished_tokens (
+    id         INT         AUTO_INCREMENT PRIMARY KEY,
+    token_id   VARCHAR(26) NOT NULL,
+    expires_at TIMESTAMP   NOT NULL,
+    UNIQUE INDEX idx_finished_tokens_token_id (token_id),
+    INDEX idx_finished_tokens_expires_at (expires_at)
+);
@@ -0,0 +1,9 @@
+package storage
+
+import "embed"
+
+// Migrations holds the numbered up/down migrations of every dialect,
+// e.g. d1/migrations/0001_init.up.sql.
+//
+//go:embed d1/migrations/*.sql mysql/migrations/*.sql
+var Migrations embed.FS